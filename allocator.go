@@ -0,0 +1,50 @@
+package expectimax
+
+import (
+	"sync"
+)
+
+// Node is a node of the search tree, as handed to an Allocator. Its contents
+// are private to the package.
+type Node expectimaxNode
+
+// Allocator supplies the nodes of every search tree in the package, in place of
+// the sync.Pool used by default, e.g. to allocate them from an arena or to check
+// for leaks. Get returns a node previously given to Put or a new zero Node, and
+// Put takes back a node no longer in any tree. Both are called from several
+// goroutines at once.
+type Allocator interface {
+	Get() *Node
+	Put(node *Node)
+}
+
+// SetAllocator makes allocator supply the package's nodes, or restores the
+// default pool if it's nil. It must be called before any Expectimax is created,
+// as nodes are put back to whichever allocator is set when they're freed.
+func SetAllocator(allocator Allocator) {
+	if allocator == nil {
+		allocator = newPoolAllocator()
+	}
+	nodeAllocator = allocator
+}
+
+// poolAllocator is the default Allocator, recycling nodes through a sync.Pool.
+type poolAllocator struct {
+	pool sync.Pool
+}
+
+func newPoolAllocator() *poolAllocator {
+	return &poolAllocator{pool: sync.Pool{
+		New: func() interface{} {
+			return new(Node)
+		},
+	}}
+}
+
+func (allocator *poolAllocator) Get() *Node {
+	return allocator.pool.Get().(*Node)
+}
+
+func (allocator *poolAllocator) Put(node *Node) {
+	allocator.pool.Put(node)
+}
@@ -0,0 +1,56 @@
+package expectimax
+
+import (
+	"sync"
+	"testing"
+)
+
+// countingAllocator allocates every node afresh and tracks which it has handed
+// out and not had back.
+type countingAllocator struct {
+	mutex       sync.Mutex
+	gets, puts  int
+	outstanding map[*Node]bool
+}
+
+func (allocator *countingAllocator) Get() *Node {
+	allocator.mutex.Lock()
+	defer allocator.mutex.Unlock()
+
+	node := new(Node)
+	allocator.gets++
+	allocator.outstanding[node] = true
+	return node
+}
+
+func (allocator *countingAllocator) Put(node *Node) {
+	allocator.mutex.Lock()
+	defer allocator.mutex.Unlock()
+
+	// Trees left over from earlier tests may still be freeing nodes from the pool
+	if allocator.outstanding[node] {
+		allocator.puts++
+		delete(allocator.outstanding, node)
+	}
+}
+
+func TestSetAllocator(t *testing.T) {
+	t.Run("test every node got from the allocator is put back after the search", func(t *testing.T) {
+		allocator := &countingAllocator{outstanding: map[*Node]bool{}}
+		SetAllocator(allocator)
+		defer SetAllocator(nil)
+
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 3)), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+		expectimax.rootNode.deleteTree(nil)
+
+		allocator.mutex.Lock()
+		defer allocator.mutex.Unlock()
+		if allocator.gets != 40 {
+			t.Errorf("Search got %d nodes, expected the 40 in the tree.", allocator.gets)
+		}
+		if allocator.puts != allocator.gets {
+			t.Errorf("Search put back %d of the %d nodes it got.", allocator.puts, allocator.gets)
+		}
+	})
+}
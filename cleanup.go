@@ -0,0 +1,94 @@
+package expectimax
+
+import "sync"
+
+// defaultCleanupWorkerCount is the most goroutines a treeCleaner runs at once
+// unless WithCleanupConcurrency says otherwise.
+const defaultCleanupWorkerCount int = 1
+
+// defaultCleanupQueueLimit is how many discarded trees may wait for a cleanup
+// goroutine before deleteTree blocks, unless WithCleanupConcurrency says otherwise.
+const defaultCleanupQueueLimit int = 64
+
+type cleanupRequest struct {
+	node       *expectimaxNode
+	exemptNode *expectimaxNode
+}
+
+// treeCleaner deletes discarded trees in the background on a bounded number of
+// goroutines, which exit once the queue is empty. The zero value is ready to use.
+type treeCleaner struct {
+	lock       sync.Mutex
+	dequeued   *sync.Cond // Signalled when a request leaves the queue, created on first use
+	queue      []cleanupRequest
+	workers    int
+	maxWorkers int // 0 for defaultCleanupWorkerCount
+	maxQueued  int // 0 for defaultCleanupQueueLimit
+}
+
+func (cleaner *treeCleaner) getMaxWorkers() int {
+	if cleaner.maxWorkers > 0 {
+		return cleaner.maxWorkers
+	}
+	return defaultCleanupWorkerCount
+}
+
+func (cleaner *treeCleaner) getMaxQueued() int {
+	if cleaner.maxQueued > 0 {
+		return cleaner.maxQueued
+	}
+	return defaultCleanupQueueLimit
+}
+
+// deleteTree queues node's tree for deletion, sparing exemptNode's subtree. If
+// the cleanup goroutines are so far behind that the queue is full, it blocks
+// until one of them takes the next tree off the queue.
+func (cleaner *treeCleaner) deleteTree(node *expectimaxNode, exemptNode *expectimaxNode) {
+	cleaner.lock.Lock()
+	defer cleaner.lock.Unlock()
+
+	if cleaner.dequeued == nil {
+		cleaner.dequeued = sync.NewCond(&cleaner.lock)
+	}
+	for len(cleaner.queue) >= cleaner.getMaxQueued() {
+		cleaner.dequeued.Wait()
+	}
+
+	cleaner.queue = append(cleaner.queue, cleanupRequest{node, exemptNode})
+	if cleaner.workers < cleaner.getMaxWorkers() {
+		cleaner.workers++
+		go cleaner.run()
+	}
+}
+
+func (cleaner *treeCleaner) run() {
+	for {
+		cleaner.lock.Lock()
+		if len(cleaner.queue) == 0 {
+			cleaner.workers--
+			cleaner.lock.Unlock()
+			return
+		}
+		request := cleaner.queue[0]
+		cleaner.queue[0] = cleanupRequest{}
+		cleaner.queue = cleaner.queue[1:]
+		cleaner.dequeued.Broadcast()
+		cleaner.lock.Unlock()
+
+		request.node.deleteTree(request.exemptNode)
+	}
+}
+
+func (cleaner *treeCleaner) getWorkerCount() int {
+	cleaner.lock.Lock()
+	defer cleaner.lock.Unlock()
+
+	return cleaner.workers
+}
+
+func (cleaner *treeCleaner) getQueueLength() int {
+	cleaner.lock.Lock()
+	defer cleaner.lock.Unlock()
+
+	return len(cleaner.queue)
+}
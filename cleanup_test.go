@@ -0,0 +1,49 @@
+package expectimax
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTreeCleaner(t *testing.T) {
+	t.Run("test rapid descents share the bounded cleanup goroutines", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 12)), testHeuristic, uniformChildLikelihood, 100000)
+		exploreAll(expectimax)
+
+		for i := 0; i < 10; i++ {
+			if err := expectimax.AdvanceToState([]interface{}{i % 2}); err != nil {
+				t.Fatalf("AdvanceToState returned %v.", err)
+			}
+			if workers := expectimax.cleaner.getWorkerCount(); workers > defaultCleanupWorkerCount {
+				t.Fatalf("%d cleanup goroutines running after %d descents, expected at most %d.", workers, i+1, defaultCleanupWorkerCount)
+			}
+		}
+
+		if !waitFor(time.Second, func() bool { return expectimax.cleaner.getWorkerCount() == 0 }) {
+			t.Errorf("Cleanup goroutines didn't exit once the queue was empty.")
+		}
+		if !referencesBalanced(expectimax.rootNode) {
+			t.Errorf("Reference counts weren't balanced after cleanup.")
+		}
+	})
+	t.Run("test rapid descents stay within the configured cleanup limits", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 100000, WithCleanupConcurrency(2, 3))
+		exploreSteps(expectimax, 50000)
+
+		for i := 0; i < 8; i++ {
+			if err := expectimax.AdvanceToState([]interface{}{i % 3}); err != nil {
+				t.Fatalf("AdvanceToState returned %v.", err)
+			}
+			if workers := expectimax.cleaner.getWorkerCount(); workers > 2 {
+				t.Fatalf("%d cleanup goroutines running after %d descents, expected at most 2.", workers, i+1)
+			}
+			if queued := expectimax.cleaner.getQueueLength(); queued > 3 {
+				t.Fatalf("%d trees queued for cleanup after %d descents, expected at most 3.", queued, i+1)
+			}
+		}
+
+		if !waitFor(time.Second, func() bool { return expectimax.cleaner.getWorkerCount() == 0 }) {
+			t.Errorf("Cleanup goroutines didn't exit once the queue was empty.")
+		}
+	})
+}
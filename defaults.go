@@ -0,0 +1,38 @@
+package expectimax
+
+import (
+	"github.com/andrew-j-armstrong/go-extensions"
+)
+
+// ZeroHeuristic values every position at 0, for running the engine on a new Game
+// before writing a real heuristic. With every value tied, the search spreads
+// roughly breadth-first.
+func ZeroHeuristic(game Game) float64 {
+	return 0.0
+}
+
+// FirstChildLikelihood expects the first of the game's possible moves to be
+// played, for games where the choice of likelihood doesn't matter yet.
+func FirstChildLikelihood(getGame func() Game, getChildValue func(interface{}) float64, childLikelihood *extensions.ValueMap) {
+	var firstMove interface{}
+	if game := getGame(); game != nil {
+		for _, move := range *game.GetPossibleMoves() {
+			if _, ok := (*childLikelihood)[move]; ok {
+				firstMove = move
+				break
+			}
+		}
+	}
+
+	for move := range *childLikelihood {
+		if firstMove == nil {
+			firstMove = move
+		}
+
+		if move == firstMove {
+			(*childLikelihood)[move] = 1.0
+		} else {
+			(*childLikelihood)[move] = 0.0
+		}
+	}
+}
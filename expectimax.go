@@ -1,8 +1,12 @@
 package expectimax
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/andrew-j-armstrong/go-extensions"
@@ -10,168 +14,1648 @@ import (
 
 type ExpectimaxHeuristic func(game Game) float64
 
+// ExpectimaxMoveHeuristic is a heuristic that is also given the move that led to
+// the game state, for heuristics that value the move itself.
+type ExpectimaxMoveHeuristic func(game Game, lastMove interface{}) float64
+
 type ExpectimaxChildLikelihoodFunc func(getGame func() Game, getChildValue func(interface{}) float64, childLikelihood *extensions.ValueMap)
 
+// ExpectimaxPriorFunc gives how promising each of a state's moves is before any
+// of them are searched, such as from a policy network. The values needn't sum to
+// 1, and moves left out are taken as 0.
+type ExpectimaxPriorFunc func(game Game) map[interface{}]float64
+
+// searchSettings holds the configuration shared by the main loop, the explore
+// workers and the nodes they operate on.
+type searchSettings struct {
+	heuristic                ExpectimaxHeuristic
+	moveHeuristic            ExpectimaxMoveHeuristic // Used instead of heuristic when set
+	calculateChildLikelihood ExpectimaxChildLikelihoodFunc
+	prior                    ExpectimaxPriorFunc // Evaluated at decision nodes when they're explored
+	priorWeight              float64             // Share of the explore probability given by the prior
+	uncertaintyWeight        float64             // Share of the explore probability given by the children's value spreads
+	alternatingPerspective   bool
+	cachePossibleMoves       bool
+	cacheGames               bool // Keep each child's game from when it was created
+	materializeInterval      int  // Keep the game of every node this many moves from the last kept one, 0 for none
+	compensatedSummation     bool // Back values up with Kahan summation
+	onNodeCreate             func() interface{}
+	proofPropagation         bool // Prove wins, draws and losses from OutcomeGame results and stop searching them
+	simultaneousSolution     SimultaneousSolution
+	drawValue                float64
+	outcomeValues            bool // Value an OutcomeGame's draws and losses by drawValue and lossValue
+	lossValue                float64
+	heuristicBlend           float64 // Descendents at which interior values are half heuristic, 0 to disable
+	valueScale               ValueScale
+	exploreTimeout           time.Duration // Longest a single Explore may take, 0 for no limit
+	widening                 progressiveWidening
+	maxBranching             int    // Most moves expanded at a decision node, 0 for no limit
+	maxDepth                 int    // Moves below the root that are expanded, 0 for no limit
+	equalCycleDetection      bool   // Value children Equal to a game on their path as draws, for unhashed EqualGames
+	validateMoves            bool   // Check generated moves with IsValidMove before exploring them
+	heuristicCalls           *int64 // Updated atomically by the workers, and shared with the copies explores run with
+	heuristicStats           *heuristicValueStatistics
+	transpositions           *transpositionTable // Heuristics of hashed positions kept for the whole game, or nil
+	transpositionGeneration  int                 // The table generation the values found with these settings belong to
+}
+
+// progressiveWidening limits decision nodes to their initialChildren best moves,
+// adding another each visitsPerChild explorations beneath them. initialChildren
+// is 0 when widening is off.
+type progressiveWidening struct {
+	initialChildren int
+	visitsPerChild  int
+}
+
+// evaluate returns the exact value of a finished game when it has one, or the
+// heuristic estimate of the game reached by lastMove otherwise.
+// evaluateHashed is evaluate for a position with the given hash, when hashed is
+// set, taking the value from the transposition table if the position has been
+// evaluated before. A move heuristic's values depend on more than the position,
+// so they're never taken from the table.
+func (settings *searchSettings) evaluateHashed(game Game, lastMove interface{}, hash uint64, hashed bool) float64 {
+	table := settings.transpositions
+	if table == nil || !hashed || settings.moveHeuristic != nil {
+		return settings.evaluate(game, lastMove)
+	}
+
+	if value, ok := table.lookup(hash, game); ok {
+		return value
+	}
+
+	value := settings.evaluate(game, lastMove)
+	table.store(hash, game, value, settings.transpositionGeneration)
+	return value
+}
+
+func (settings *searchSettings) evaluate(game Game, lastMove interface{}) float64 {
+	if game.IsGameOver() {
+		if outcomeGame, ok := game.(OutcomeGame); ok && settings.outcomeValues {
+			switch outcomeGame.GetOutcome() {
+			case DrawOutcome:
+				return settings.drawValue
+			case LossOutcome:
+				return settings.lossValue
+			}
+		}
+
+		if terminalValueGame, ok := game.(TerminalValueGame); ok {
+			if value, ok := terminalValueGame.TerminalValue(); ok {
+				return value
+			}
+		}
+	}
+
+	atomic.AddInt64(settings.heuristicCalls, 1)
+	var value float64
+	if settings.moveHeuristic != nil {
+		value = settings.moveHeuristic(game, lastMove)
+	} else {
+		value = settings.heuristic(game)
+	}
+	settings.heuristicStats.record(value)
+
+	return value
+}
+
 type Expectimax struct {
 	game                          Game // Current game state
-	heuristic                     ExpectimaxHeuristic
-	calculateChildLikelihood      ExpectimaxChildLikelihoodFunc
+	settings                      *searchSettings
 	rootNode                      *expectimaxNode
-	bestMoveChannelReceiver       chan (chan<- interface{})
+	bestMoveChannelReceiver       chan bestMoveRequest
 	nextMoveChannelReceiver       chan (chan<- *extensions.ValueMap)
 	unexploredNodeReceiverChannel chan chan<- *expectimaxNode
 	exploredNodeChannel           chan *expectimaxNode
+	requestChannel                chan func()
+	moveListener                  chan interface{}
+	moveListenerBufferSize        int
+	pendingMoves                  []interface{} // Taken from moveListener during a long step, main loop only
+	started                       int32         // Set once the main loop has been started, as it only runs once
+	running                       int32
+	mainLoopDone                  chan struct{} // Closed once RunExpectimax's loop has returned
+	inlineLock                    sync.Mutex    // Held by requests run off the main loop
+	paused                        int32
+	quit                          chan struct{}
+	stopOnce                      sync.Once
+	searchTimeout                 time.Duration
+	responsePollInterval          time.Duration
+	exploredNodeBufferSize        int
 	maxNodeCount                  int
+	minResponseFraction           float64 // Fraction of maxNodeCount explored before answering
+	minResponseNodes              int     // Nodes explored before answering, or -1 to use minResponseFraction
 	printDebugMessages            bool
+	onExplore                     func(move interface{}, childCount int, value float64)
+	onBestMoveChange              func(change BestMoveChange)
+	onError                       func(err error)
+	watchdogTimeout               time.Duration // How long without progress before a stall is reported, 0 for no watchdog
+	watchdogStop                  bool          // Stop the search once a stall is reported
+	stopCondition                 func(stats SearchStats) bool
+	onBudgetReached               func(stats SearchStats)
+	recordMoveHistory             bool
+	moveHistory                   []MoveRecord  // Main loop only
+	budgetReached                 bool          // onBudgetReached has fired for the current root, main loop only
+	resignThreshold               float64       // Root value below which a re-root counts towards resigning
+	resignPersistence             int           // Re-roots the value must stay below resignThreshold for, 0 to never resign
+	resignCount                   int           // Re-roots in a row leaving a root valued below resignThreshold, main loop only
+	stopConditionMet              int32         // Set once stopCondition holds, or defaultDeadline passes, for the current root
+	defaultDeadline               time.Duration // Longest each root is searched, 0 for no limit
+	rootTime                      time.Time     // When the current root was set
+	rand                          Rand          // Main loop only
+	randomTieBreaking             bool
+	rootSelectionPolicy           RootSelectionPolicy
+	rootChildFloor                int           // Nodes each root move's subtree gets before best-first takes over
+	rootRotation                  int           // Where the next root move floor search starts, main loop only
+	rootChildMoves                []interface{} // The root's moves in the game's order for the floor, main loop only
+	exploreRootOnCreate           bool
+	bestMove                      interface{} // Last best move given to onBestMoveChange
+	inFlight                      int         // Nodes handed to workers and not yet processed, main loop only
+	exhaustedPolicy               ExhaustedPolicy
+	explorationOrder              ExplorationOrder
+	frontier                      explorationFrontier      // Queued nodes for BreadthFirst, main loop only
+	idleWorkers                   []chan<- *expectimaxNode // Workers parked with nothing to explore, main loop only
+	idleWorkerCount               int32                    // len(idleWorkers), for IsCurrentlySearching
+	stats                         searchStatistics
+	convergence                   convergenceTracker // Main loop only
+	cleaner                       treeCleaner
+	keepSubtree                   func(move interface{}) bool     // Which of the old root's other moves to keep on a descent
+	keptSubtrees                  map[interface{}]*expectimaxNode // Kept by keepSubtree on the last descent, main loop only
+	workerStats                   []*workerStatistics             // One per worker, nil unless enabled
+}
+
+// runOnMainLoop runs request on the main loop so it sees the tree between
+// updates, or immediately if RunExpectimax isn't running. If the loop returns
+// before getting to the request, such as after Stop, the request is run here
+// instead. Requests run here hold inlineLock, so they don't overlap each other
+// or RunExpectimax starting.
+func (this *Expectimax) runOnMainLoop(request func()) {
+	this.inlineLock.Lock()
+	if atomic.LoadInt32(&this.running) == 0 {
+		defer this.inlineLock.Unlock()
+		request()
+		return
+	}
+	this.inlineLock.Unlock()
+
+	var claimed int32
+	claim := func() bool {
+		return atomic.CompareAndSwapInt32(&claimed, 0, 1)
+	}
+	runInline := func() {
+		if claim() {
+			this.inlineLock.Lock()
+			defer this.inlineLock.Unlock()
+			request()
+		}
+	}
+
+	done := make(chan struct{})
+	select {
+	case this.requestChannel <- func() {
+		if claim() {
+			request()
+		}
+		close(done)
+	}:
+	case <-this.mainLoopDone:
+		runInline()
+		return
+	}
+
+	select {
+	case <-done:
+	case <-this.mainLoopDone:
+		runInline() // Left in requestChannel when the loop returned
+	}
+}
+
+// Walk calls visit for each node in the tree, depth-first from the root (depth 0,
+// nil move), until visit returns false. The tree is copied on the main loop
+// first, so visit may safely call back into the Expectimax.
+func (this *Expectimax) Walk(visit func(depth int, move interface{}, value, heuristic float64, status string) bool) {
+	this.WalkUserData(func(depth int, move interface{}, value, heuristic float64, status string, userData interface{}) bool {
+		return visit(depth, move, value, heuristic, status)
+	})
+}
+
+// WalkUserData is Walk, also giving visit each node's data from WithOnNodeCreate.
+func (this *Expectimax) WalkUserData(visit func(depth int, move interface{}, value, heuristic float64, status string, userData interface{}) bool) {
+	var snapshot []nodeSnapshot
+	this.runOnMainLoop(func() {
+		snapshot = this.rootNode.appendSnapshot(snapshot, 0, nil)
+	})
+
+	for _, entry := range snapshot {
+		if !visit(entry.depth, entry.move, entry.value, entry.heuristic, entry.status.String(), entry.userData) {
+			return
+		}
+	}
+}
+
+// TreeNode is an exported copy of a node in the search tree.
+type TreeNode struct {
+	Move       interface{} // Move from the parent, nil at the root
+	Value      float64     // Backed-up value, from player 0's point of view
+	Heuristic  float64
+	Likelihood float64     // Likelihood of Move being played from the parent, 1 at the root
+	UserData   interface{} // Given by WithOnNodeCreate
+	SkipReason SkipReason  // Why the search declined to explore the node, if it did
+	Children   []*TreeNode // Moves held back by progressive widening come last, with no values
+}
+
+// SkipReason is why the search declined to explore a node it reached. A node
+// that's unexplored with NotSkipped simply hasn't come up yet, as likelier nodes
+// have been explored first.
+type SkipReason int
+
+const (
+	NotSkipped         SkipReason = iota
+	SkippedForDepth               // Past WithMaxDepth, so kept as a leaf
+	SkippedForTimeout             // Exploring it took longer than WithExploreTimeout, so kept as a leaf
+	SkippedAsRepeat               // Repeats a position further up its line, so valued as a draw, or is a no-op kept as its parent's only move
+	SkippedForBudget              // It was next to explore when the node budget ran out, and still is
+	SkippedAsProven               // Below a node proven won, drawn or lost, which needs no more search
+	SkippedForWidening            // A move WithProgressiveWidening holds back until its parent has more visits
+)
+
+// TreeSpec describes a search tree for NewExpectimaxFromRoot to build. Nodes with
+// children are taken as explored. Leaves are left for the search to explore
+// unless they're Terminal.
+type TreeSpec struct {
+	Move      interface{} // Move from the parent, ignored at the root
+	Heuristic float64     // The node's own estimate, and its value if it's a leaf
+	Terminal  bool        // A leaf that will never be explored, such as a finished game
+	Children  []*TreeSpec
+}
+
+// ExportTree copies the search tree down to maxDepth moves below the root, or
+// all of it if maxDepth is negative. The copy is taken on the main loop and
+// shares nothing with the search. Children are in no particular order.
+func (this *Expectimax) ExportTree(maxDepth int) *TreeNode {
+	var treeNode *TreeNode
+	this.runOnMainLoop(func() {
+		treeNode = this.rootNode.exportTree(nil, 1.0, maxDepth, this.rootNode.descendentCount >= this.maxNodeCount, false)
+	})
+
+	return treeNode
+}
+
+// bestMoveRequest asks the main loop for the best move once the search is deep
+// enough.
+type bestMoveRequest struct {
+	reply chan<- interface{}
+	done  <-chan struct{} // Closed once the caller stops waiting, nil if it never does
+}
+
+// abandoned reports whether the caller has stopped waiting for the reply.
+func (request bestMoveRequest) abandoned() bool {
+	select {
+	case <-request.done:
+		return true
+	default:
+		return false
+	}
 }
 
+// GetBestMove blocks until the search is deep enough and returns the best move.
+// It is safe to call from several goroutines at once: each request carries its
+// own reply channel, so every caller receives exactly one answer.
 func (this *Expectimax) GetBestMove() interface{} {
-	bestMoveChannel := make(chan interface{})
+	bestMove, _ := this.GetBestMoveContext(context.Background())
+	return bestMove
+}
+
+// GetBestMoveContext is GetBestMove, except that once ctx is done it stops
+// waiting for the search to explore enough and returns the best move as it
+// stands, along with ctx.Err(). The abandoned request is dropped rather than
+// left waiting on the main loop.
+func (this *Expectimax) GetBestMoveContext(ctx context.Context) (interface{}, error) {
+	bestMoveChannel := make(chan interface{}, 1)
+
+	select {
+	case this.bestMoveChannelReceiver <- bestMoveRequest{reply: bestMoveChannel, done: ctx.Done()}:
+		select {
+		case bestMove := <-bestMoveChannel:
+			return bestMove, nil
+		case <-ctx.Done():
+		}
+	case <-ctx.Done():
+	}
 
-	this.bestMoveChannelReceiver <- bestMoveChannel
+	var bestMove interface{}
+	this.runOnMainLoop(func() {
+		bestMove = this.getBestChildMove()
+	})
 
-	return <-bestMoveChannel
+	return bestMove, ctx.Err()
+}
+
+// MoveValue is one of the root's moves with its value, from player 0's point of
+// view, as in GetNextMoveValues.
+type MoveValue struct {
+	Move  interface{}
+	Value float64
+}
+
+// GetBestMoveN waits as GetBestMove does and returns up to n of the root's moves,
+// best first for the player to move. Ties are ranked as GetBestMove breaks them,
+// except that WithRandomTieBreaking makes no difference.
+func (this *Expectimax) GetBestMoveN(n int) []MoveValue {
+	for {
+		var bestMoves []MoveValue
+		var deepEnough bool
+		this.runOnMainLoop(func() {
+			if deepEnough = this.isDeepEnough(); deepEnough {
+				bestMoves = this.getBestMoves(n)
+			}
+		})
+		if deepEnough {
+			return bestMoves
+		}
+
+		time.Sleep(this.responsePollInterval)
+	}
+}
+
+// getBestMoves returns up to n of the root's moves, best first. It must be
+// called from the main loop.
+func (this *Expectimax) getBestMoves(n int) []MoveValue {
+	childMoves := this.rootNode.getOrderedChildMoves()
+	sort.SliceStable(childMoves, func(i, j int) bool {
+		first, second := this.rootNode.children[childMoves[i]], this.rootNode.children[childMoves[j]]
+		if firstValue, secondValue := this.rootNode.perspective*first.value, this.rootNode.perspective*second.value; firstValue != secondValue {
+			return firstValue > secondValue
+		}
+		return first.descendentCount > second.descendentCount
+	})
+
+	if n < 0 {
+		n = 0
+	}
+	if n < len(childMoves) {
+		childMoves = childMoves[:n]
+	}
+
+	bestMoves := make([]MoveValue, 0, len(childMoves))
+	for _, move := range childMoves {
+		bestMoves = append(bestMoves, MoveValue{Move: move, Value: this.rootNode.children[move].value})
+	}
+
+	return bestMoves
 }
 
 func (this *Expectimax) GetNextMoveValues() *extensions.ValueMap {
-	nextMoveValuesChannel := make(chan *extensions.ValueMap)
+	nextMoveValuesChannel := make(chan *extensions.ValueMap, 1)
 
 	this.nextMoveChannelReceiver <- nextMoveValuesChannel
 
 	return <-nextMoveValuesChannel
 }
 
+// GetNextMoveValuesContext is GetNextMoveValues, except that once ctx is done it
+// stops waiting for the search to explore enough and returns the values as they
+// stand, along with ctx.Err().
+func (this *Expectimax) GetNextMoveValuesContext(ctx context.Context) (*extensions.ValueMap, error) {
+	ticker := time.NewTicker(this.responsePollInterval)
+	defer ticker.Stop()
+
+	for {
+		nextMoveValues, deepEnough := this.GetNextMoveValuesNow()
+		if deepEnough {
+			return nextMoveValues, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			nextMoveValues, _ = this.GetNextMoveValuesNow()
+			return nextMoveValues, ctx.Err()
+		}
+	}
+}
+
+// IsFullyExplored reports whether every reachable position has been explored and
+// processed, so the values reported are exact rather than estimates.
+func (this *Expectimax) IsFullyExplored() bool {
+	var fullyExplored bool
+	this.runOnMainLoop(func() {
+		fullyExplored = this.rootNode.mostLikelyUnexploredDescendent == nil && this.inFlight == 0
+	})
+
+	return fullyExplored
+}
+
+// GetValue returns the root's backed-up value, from player 0's point of view.
+func (this *Expectimax) GetValue() float64 {
+	var value float64
+	this.runOnMainLoop(func() {
+		value = this.rootNode.value
+	})
+
+	return value
+}
+
+// IsCurrentlySearching reports whether the search still has work to do for the
+// current root, or is still doing it. It's safe to poll from any goroutine.
 func (this *Expectimax) IsCurrentlySearching() bool {
-	if this.rootNode == nil {
+	var searching bool
+	this.runOnMainLoop(func() {
+		searching = this.isCurrentlySearching()
+	})
+
+	return searching
+}
+
+// isCurrentlySearching is IsCurrentlySearching. It must be called from the main
+// loop.
+func (this *Expectimax) isCurrentlySearching() bool {
+	if this.rootNode == nil || this.isStopped() || atomic.LoadInt32(&this.stopConditionMet) != 0 {
 		return false
 	}
 
 	return this.rootNode.descendentCount < this.maxNodeCount &&
 		(this.rootNode.mostLikelyUnexploredDescendent != nil ||
-			len(this.unexploredNodeReceiverChannel) != expectimaxWorkerCount ||
+			len(this.unexploredNodeReceiverChannel)+int(atomic.LoadInt32(&this.idleWorkerCount)) != expectimaxWorkerCount ||
 			len(this.exploredNodeChannel) != 0)
 }
 
-func (this *Expectimax) sendBestMove(bestMoveChannel chan<- interface{}) {
-	if this.rootNode.descendentCount < this.maxNodeCount/100 && this.rootNode.mostLikelyUnexploredDescendent != nil {
+// StopReason says why the search isn't exploring any further.
+type StopReason int
+
+const (
+	// NotStopped means the search is still going, or is only paused.
+	NotStopped StopReason = iota
+	// BudgetReached means the tree is at the maximum node count, so a larger
+	// budget would let the search go further.
+	BudgetReached
+	// FullyExplored means every reachable position has been explored, so the
+	// values are exact and more budget wouldn't change them.
+	FullyExplored
+	// Stopped means Stop has been called.
+	Stopped
+	// GameOver means the root's game is over, so there's nothing to search.
+	GameOver
+	// Cancelled means the stop condition or default deadline cut the search of
+	// the current root short.
+	Cancelled
+)
+
+func (reason StopReason) String() string {
+	switch reason {
+	case NotStopped:
+		return "NotStopped"
+	case BudgetReached:
+		return "BudgetReached"
+	case FullyExplored:
+		return "FullyExplored"
+	case Stopped:
+		return "Stopped"
+	case GameOver:
+		return "GameOver"
+	case Cancelled:
+		return "Cancelled"
+	}
+
+	return fmt.Sprintf("StopReason(%d)", int(reason))
+}
+
+// StopReason returns why the search has stopped exploring, or NotStopped while
+// it's still going. When more than one reason holds, the first of Stopped,
+// GameOver, FullyExplored, Cancelled and BudgetReached is given.
+func (this *Expectimax) StopReason() StopReason {
+	var reason StopReason
+	this.runOnMainLoop(func() {
+		reason = this.getStopReason()
+	})
+
+	return reason
+}
+
+func (this *Expectimax) getStopReason() StopReason {
+	switch {
+	case this.isStopped():
+		return Stopped
+	case this.rootNode == nil:
+		return NotStopped
+	case this.rootNode.game != nil && this.rootNode.game.IsGameOver():
+		return GameOver
+	case this.rootNode.mostLikelyUnexploredDescendent == nil && this.inFlight == 0:
+		return FullyExplored
+	case atomic.LoadInt32(&this.stopConditionMet) != 0:
+		return Cancelled
+	case this.rootNode.descendentCount >= this.maxNodeCount:
+		return BudgetReached
+	}
+
+	return NotStopped
+}
+
+// processExploredNode backs up a node once its exploration has finished. It must
+// only be called from the main loop.
+func (this *Expectimax) processExploredNode(node *expectimaxNode) {
+	this.processExploredNodes([]*expectimaxNode{node})
+}
+
+// processExploredNodes backs up a batch of explored nodes, recalculating each
+// ancestor they share once for the batch. It must only be called from the main
+// loop.
+func (this *Expectimax) processExploredNodes(nodes []*expectimaxNode) {
+	backups := newBackupBatch()
+	processedNodes := nodes[:0:0]
+	for _, node := range nodes {
+		if node.found != nil {
+			node.attachExploration(this.settings, node.found)
+			node.found = nil
+		}
+		if node.exploreError != nil {
+			this.reportError(node.exploreError)
+			node.exploreError = nil
+		}
+
+		if node.processExploredNode(this.settings, backups) {
+			processedNodes = append(processedNodes, node)
+		}
+	}
+	backups.flush(this.settings)
+
+	if len(processedNodes) == 0 {
+		return
+	}
+
+	for _, node := range processedNodes {
+		this.frontier.pushChildren(node.orderedChildren)
+		if this.settings.widening.initialChildren > 0 {
+			for visitedNode := node; visitedNode != nil; visitedNode = visitedNode.parent {
+				visitedNode.visitCount++
+				if len(visitedNode.pendingMoves) > 0 {
+					childCount := len(visitedNode.orderedChildren)
+					visitedNode.widen(this.settings)
+					this.frontier.pushChildren(visitedNode.orderedChildren[childCount:])
+					if visitedNode.exploreError != nil {
+						this.reportError(visitedNode.exploreError)
+						visitedNode.exploreError = nil
+					}
+				}
+			}
+		}
+
+		this.stats.recordExploredNode()
+		this.convergence.record(this.getBestChildValue())
+		if this.onExplore != nil {
+			this.onExplore(node.lastMove, len(node.children), node.value)
+		}
+	}
+
+	if this.onBestMoveChange != nil {
+		this.checkBestMoveChange()
+	}
+	if this.stopCondition != nil && this.stopCondition(this.getSearchStats()) {
+		atomic.StoreInt32(&this.stopConditionMet, 1)
+	}
+}
+
+// reportError passes a problem met during the search to onError, or logs it if
+// there's no error callback.
+func (this *Expectimax) reportError(err error) {
+	if this.onError != nil {
+		this.onError(err)
+	} else {
+		log.Print(err)
+	}
+}
+
+// BestMoveChange describes the root's best move changing as the search goes on.
+// Values are from player 0's point of view, as in GetNextMoveValues.
+type BestMoveChange struct {
+	From, To           interface{} // From is nil when the root's moves have just been found
+	FromValue, ToValue float64
+	NodeCount          int // Root descendents when the change was seen
+}
+
+// checkBestMoveChange reports a change in the root's best move to
+// onBestMoveChange. It must be called from the main loop.
+func (this *Expectimax) checkBestMoveChange() {
+	bestMove := this.getBestChildMove()
+	if bestMove == nil || bestMove == this.bestMove {
+		return
+	}
+
+	change := BestMoveChange{From: this.bestMove, To: bestMove, ToValue: this.rootNode.children[bestMove].value, NodeCount: this.rootNode.descendentCount}
+	if fromNode, ok := this.rootNode.children[this.bestMove]; ok && this.bestMove != nil {
+		change.FromValue = fromNode.value
+	}
+
+	this.bestMove = bestMove
+	this.onBestMoveChange(change)
+}
+
+func (this *Expectimax) sendBestMove(request bestMoveRequest) {
+	if !this.isDeepEnough() {
 		// Wait for more depth to be explored
-		go func() {
-			time.Sleep(time.Duration(100) * time.Millisecond)
-			this.bestMoveChannelReceiver <- bestMoveChannel
-		}()
+		this.requeueBestMoveRequest(request, this.responsePollInterval)
 	} else {
-		var bestChildMove interface{}
-		var bestChildValue float64
-		for childMove, childNode := range this.rootNode.children {
-			if bestChildMove == nil || bestChildValue < childNode.value {
-				bestChildMove = childMove
-				bestChildValue = childNode.value
+		request.reply <- this.getBestChildMove()
+	}
+}
+
+// GetNextMoveValuesNow returns the values of the root's children as they stand,
+// without waiting, and whether the search had explored enough for
+// GetNextMoveValues to answer. The map is empty before the root is explored.
+func (this *Expectimax) GetNextMoveValuesNow() (*extensions.ValueMap, bool) {
+	var nextMoveValues *extensions.ValueMap
+	var deepEnough bool
+	this.runOnMainLoop(func() {
+		nextMoveValues = this.getNextMoveValues()
+		deepEnough = this.isDeepEnough()
+	})
+
+	return nextMoveValues, deepEnough
+}
+
+// GetRootMoveLikelihoods returns how likely each of the root's moves is to be
+// played, as the child likelihood function judges from their values, or as the
+// game gives at a chance node. GetNextMoveValues gives what each move is worth;
+// this gives how probable it is, which is what the root's value weighs those
+// worths by. They sum to 1.
+func (this *Expectimax) GetRootMoveLikelihoods() map[interface{}]float64 {
+	likelihoods := map[interface{}]float64{}
+	this.runOnMainLoop(func() {
+		for move, likelihood := range this.rootNode.childLikelihood {
+			likelihoods[move] = likelihood
+		}
+	})
+
+	return likelihoods
+}
+
+// GetRootChildHeuristics returns the heuristic value of the position after each of
+// the root's moves, as first evaluated before any search beneath it. Set against
+// GetNextMoveValues, it shows how far the search has moved each estimate.
+func (this *Expectimax) GetRootChildHeuristics() map[interface{}]float64 {
+	heuristics := map[interface{}]float64{}
+	this.runOnMainLoop(func() {
+		for move, childNode := range this.rootNode.children {
+			heuristics[move] = childNode.heuristic
+		}
+	})
+
+	return heuristics
+}
+
+// GetNextMoveValueDeltas returns how much worse each of the root's moves is than
+// the move GetBestMove would pick, as engines usually show them: 0 for the best
+// move and negative for the rest. Unlike GetNextMoveValues, deltas are from the
+// point of view of the player to move at the root. The map is empty before the
+// root is explored.
+func (this *Expectimax) GetNextMoveValueDeltas() map[interface{}]float64 {
+	deltas := map[interface{}]float64{}
+	this.runOnMainLoop(func() {
+		bestNode, ok := this.rootNode.children[this.getBestChildMove()]
+		if !ok {
+			return
+		}
+
+		for move, childNode := range this.rootNode.children {
+			deltas[move] = this.rootNode.perspective * (childNode.value - bestNode.value)
+		}
+	})
+
+	return deltas
+}
+
+// GetRootChildDepths returns the average depth the search has reached beneath each
+// of the root's moves, 0 for a move that hasn't been searched past. Comparing
+// moves searched to very different depths is less fair than their values
+// suggest.
+func (this *Expectimax) GetRootChildDepths() map[interface{}]float64 {
+	depths := map[interface{}]float64{}
+	this.runOnMainLoop(func() {
+		for move, childNode := range this.rootNode.children {
+			depths[move] = childNode.averageDepth
+		}
+	})
+
+	return depths
+}
+
+// GetRootExploreProbabilities returns the chance of the search going down each of
+// the root's moves, as the child likelihoods with the exploration spread and any
+// FocusOn weights applied. Without weights they sum to 1.
+func (this *Expectimax) GetRootExploreProbabilities() map[interface{}]float64 {
+	exploreProbabilities := map[interface{}]float64{}
+	this.runOnMainLoop(func() {
+		for move, exploreProbability := range this.rootNode.childExploreProbability {
+			exploreProbabilities[move] = exploreProbability
+		}
+	})
+
+	return exploreProbabilities
+}
+
+// isDeepEnough reports whether enough of the tree has been explored to answer
+// best and next move queries. The root must always have been explored.
+func (this *Expectimax) isDeepEnough() bool {
+	if this.rootNode.explorationStatus != Archived {
+		return false // Not even the root's moves are known yet
+	}
+	if atomic.LoadInt32(&this.stopConditionMet) != 0 {
+		return true
+	}
+
+	minResponseNodes := this.minResponseNodes
+	if minResponseNodes < 0 {
+		minResponseNodes = int(this.minResponseFraction * float64(this.maxNodeCount))
+	}
+
+	return this.rootNode.descendentCount >= minResponseNodes || this.rootNode.mostLikelyUnexploredDescendent == nil
+}
+
+func (this *Expectimax) getNextMoveValues() *extensions.ValueMap {
+	nextMoveValues := extensions.ValueMap{}
+	for childMove, childNode := range this.rootNode.children {
+		nextMoveValues[childMove] = childNode.value
+	}
+
+	return &nextMoveValues
+}
+
+// requeueBestMoveRequest puts a request back on bestMoveChannelReceiver after
+// delay. It never blocks the main loop, which would deadlock if the request
+// channel were full.
+func (this *Expectimax) requeueBestMoveRequest(request bestMoveRequest, delay time.Duration) {
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-request.done:
+			return
+		}
+
+		select {
+		case this.bestMoveChannelReceiver <- request:
+		case <-request.done:
+		}
+	}()
+}
+
+func (this *Expectimax) requeueNextMoveRequest(nextMoveChannel chan<- *extensions.ValueMap, delay time.Duration) {
+	go func() {
+		time.Sleep(delay)
+		this.nextMoveChannelReceiver <- nextMoveChannel
+	}()
+}
+
+// getBestChildMove returns the root's best move under the root selection policy:
+// the highest value, with ties going to the move searched more deeply, or the
+// most deeply searched, with ties going to the higher value. Remaining ties go to
+// the move the game lists first, or a random one with WithRandomTieBreaking. A
+// move proven to win comes before all of them.
+func (this *Expectimax) getBestChildMove() interface{} {
+	if win := this.rootNode.winningOutcome(); this.rootNode.proof == win {
+		for _, childMove := range this.rootNode.getOrderedChildMoves() {
+			if this.rootNode.children[childMove].proof == win {
+				return childMove
+			}
+		}
+	}
+
+	var tiedMoves []interface{}
+	var bestRank, bestTieBreak float64
+	for childMove, childNode := range this.rootNode.children {
+		rank, tieBreak := this.rootNode.perspective*childNode.value, float64(childNode.descendentCount)
+		if this.rootSelectionPolicy == SelectMostVisited {
+			rank, tieBreak = tieBreak, rank
+		}
+
+		switch {
+		case tiedMoves == nil || bestRank < rank || (bestRank == rank && bestTieBreak < tieBreak):
+			tiedMoves = append(tiedMoves[:0], childMove)
+			bestRank, bestTieBreak = rank, tieBreak
+		case bestRank == rank && bestTieBreak == tieBreak:
+			tiedMoves = append(tiedMoves, childMove)
+		}
+	}
+
+	if len(tiedMoves) == 0 {
+		return nil
+	} else if len(tiedMoves) == 1 {
+		return tiedMoves[0]
+	}
+
+	if this.randomTieBreaking {
+		return tiedMoves[this.rand.Intn(len(tiedMoves))]
+	}
+
+	for _, move := range this.rootNode.getOrderedChildMoves() {
+		for _, tiedMove := range tiedMoves {
+			if move == tiedMove {
+				return move
 			}
 		}
+	}
+
+	return tiedMoves[0]
+}
 
-		bestMoveChannel <- bestChildMove
+// GetPrincipalVariation returns the best move followed by the most likely line of
+// play after it, as far as the tree has been explored.
+func (this *Expectimax) GetPrincipalVariation() []interface{} {
+	var principalVariation []interface{}
+	this.runOnMainLoop(func() {
+		principalVariation = this.getPrincipalVariation()
+	})
+
+	return principalVariation
+}
+
+func (this *Expectimax) getPrincipalVariation() []interface{} {
+	bestChildMove := this.getBestChildMove()
+	if bestChildMove == nil {
+		return []interface{}{}
+	}
+
+	return append([]interface{}{bestChildMove}, this.rootNode.children[bestChildMove].getMostLikelyLine()...)
+}
+
+// ExpectedGameLength returns the number of plies along the principal variation,
+// up to the first terminal or unexplored node. While the search is incomplete
+// it is a lower bound on the length of the expected line of play.
+func (this *Expectimax) ExpectedGameLength() float64 {
+	var length float64
+	this.runOnMainLoop(func() {
+		length = float64(len(this.getPrincipalVariation()))
+	})
+
+	return length
+}
+
+// GameAt returns a copy of the game at the node reached from the root by moves,
+// which may be changed freely. An error is returned if moves leave the tree
+// searched so far.
+func (this *Expectimax) GameAt(moves []interface{}) (Game, error) {
+	var game Game
+	var err error
+	this.runOnMainLoop(func() {
+		node := this.rootNode
+		for i, move := range moves {
+			childNode, ok := node.children[move]
+			if !ok {
+				err = fmt.Errorf("expectimax: move %d (%v) is not in the searched tree", i, move)
+				return
+			}
+			node = childNode
+		}
+
+		if game = node.GetGame(); game == nil {
+			err = fmt.Errorf("expectimax: the game after moves %v is no longer available", moves)
+		}
+	})
+
+	return game, err
+}
+
+// Restart replaces the game being searched, freeing the old tree and keeping
+// the workers. It waits for the workers to finish the old tree's nodes they
+// have. Moves made on the old game are ignored from then on.
+func (this *Expectimax) Restart(game Game) {
+	this.runOnMainLoop(func() {
+		this.setGame(game)
+	})
+}
+
+// SetHeuristic replaces the heuristic given to the constructor, for changing the
+// evaluation as the game goes on, such as a sharper one for the endgame. Nodes
+// explored from then on use it, but the values already in the tree aren't
+// recomputed unless RecomputeValues is called, so until then it takes a while
+// for the new heuristic to show at the root.
+// Any transposition table is emptied, as its values came from the old one. A
+// heuristic set by WithMoveHeuristic is still used in its place.
+func (this *Expectimax) SetHeuristic(heuristic ExpectimaxHeuristic) {
+	if heuristic == nil {
+		log.Printf("expectimax: no heuristic given, using ZeroHeuristic")
+		heuristic = ZeroHeuristic
+	}
+
+	this.runOnMainLoop(func() {
+		this.waitForInFlightNodes()
+		this.settings.heuristic = heuristic
+		if this.settings.transpositions != nil {
+			this.settings.transpositionGeneration = this.settings.transpositions.clear()
+		}
+	})
+}
+
+// SetChildLikelihood replaces the child likelihood function given to the
+// constructor. Like SetHeuristic, it applies to the likelihoods worked out from
+// then on, as nodes are explored and values backed up through them, while the
+// likelihoods already in the tree are left as they are.
+func (this *Expectimax) SetChildLikelihood(calculateChildLikelihood ExpectimaxChildLikelihoodFunc) {
+	if calculateChildLikelihood == nil {
+		log.Printf("expectimax: no child likelihood function given, using FirstChildLikelihood")
+		calculateChildLikelihood = FirstChildLikelihood
+	}
+
+	this.runOnMainLoop(func() {
+		this.waitForInFlightNodes()
+		this.settings.calculateChildLikelihood = calculateChildLikelihood
+	})
+}
+
+// RecomputeValues evaluates every node in the tree again with the current
+// heuristic and backs the values up from the leaves, so the analysis already
+// done reflects a heuristic changed by SetHeuristic. It visits the whole tree,
+// and the search waits for the nodes being explored and then stalls while it
+// runs.
+func (this *Expectimax) RecomputeValues() {
+	this.runOnMainLoop(func() {
+		this.waitForInFlightNodes()
+		this.rootNode.recomputeValues(this.settings)
+		if this.onBestMoveChange != nil {
+			this.checkBestMoveChange()
+		}
+	})
+}
+
+// waitForInFlightNodes processes the nodes handed to the workers until none are
+// left, so no worker is using the settings. As it runs on the main loop, no more
+// are handed out until it returns. It must be called from the main loop.
+func (this *Expectimax) waitForInFlightNodes() {
+	for this.inFlight > 0 {
+		this.drainMoveListener()
+		var exploredNode *expectimaxNode
+		select {
+		case exploredNode = <-this.exploredNodeChannel:
+		case <-this.quit:
+			return // The workers have stopped, so the rest won't arrive
+		}
+		this.inFlight--
+		this.processExploredNode(exploredNode)
+		exploredNode.decrementReference()
+	}
+}
+
+// Snapshot returns an independent copy of the Expectimax and its tree, for
+// trying out lines without disturbing the search. The copy has its own copy of
+// the game, isn't running, and can be advanced, queried or run separately.
+// Nodes being explored when the snapshot is taken are unexplored in the copy,
+// and the copy gets a default Rand of its own rather than sharing WithRand's.
+func (this *Expectimax) Snapshot() *Expectimax {
+	var snapshot *Expectimax
+	this.runOnMainLoop(func() {
+		settings := *this.settings
+		heuristicCalls := atomic.LoadInt64(this.settings.heuristicCalls)
+		settings.heuristicCalls = &heuristicCalls
+		settings.heuristicStats = this.settings.heuristicStats.copy()
+		snapshot = &Expectimax{
+			settings:                &settings,
+			rootNode:                this.rootNode.copyTree(nil),
+			bestMoveChannelReceiver: make(chan bestMoveRequest, 10),
+			nextMoveChannelReceiver: make(chan (chan<- *extensions.ValueMap), 10),
+			requestChannel:          make(chan func(), 10),
+			paused:                  atomic.LoadInt32(&this.paused),
+			quit:                    make(chan struct{}),
+			mainLoopDone:            make(chan struct{}),
+			searchTimeout:           this.searchTimeout,
+			responsePollInterval:    this.responsePollInterval,
+			exploredNodeBufferSize:  this.exploredNodeBufferSize,
+			moveListenerBufferSize:  this.moveListenerBufferSize,
+			maxNodeCount:            this.maxNodeCount,
+			minResponseFraction:     this.minResponseFraction,
+			minResponseNodes:        this.minResponseNodes,
+			printDebugMessages:      this.printDebugMessages,
+			onExplore:               this.onExplore,
+			onBestMoveChange:        this.onBestMoveChange,
+			onError:                 this.onError,
+			stopCondition:           this.stopCondition,
+			onBudgetReached:         this.onBudgetReached,
+			recordMoveHistory:       this.recordMoveHistory,
+			moveHistory:             append([]MoveRecord(nil), this.moveHistory...),
+			budgetReached:           this.budgetReached,
+			resignThreshold:         this.resignThreshold,
+			resignPersistence:       this.resignPersistence,
+			resignCount:             this.resignCount,
+			defaultDeadline:         this.defaultDeadline,
+			stopConditionMet:        atomic.LoadInt32(&this.stopConditionMet),
+			rootTime:                this.rootTime,
+			rand:                    newDefaultRand(),
+			randomTieBreaking:       this.randomTieBreaking,
+			rootSelectionPolicy:     this.rootSelectionPolicy,
+			rootChildFloor:          this.rootChildFloor,
+			convergence:             this.convergence.copy(),
+			exhaustedPolicy:         this.exhaustedPolicy,
+			explorationOrder:        this.explorationOrder,
+			bestMove:                this.bestMove,
+			cleaner:                 treeCleaner{maxWorkers: this.cleaner.maxWorkers, maxQueued: this.cleaner.maxQueued},
+		}
+	})
+
+	if this.workerStats != nil {
+		WithWorkerStats(true)(snapshot)
+	}
+
+	snapshot.game = snapshot.rootNode.GetGame()
+	snapshot.moveListener = make(chan interface{}, snapshot.moveListenerBufferSize)
+	snapshot.game.RegisterMoveListener(snapshot.moveListener)
+	snapshot.stats.sample(time.Now())
+
+	return snapshot
+}
+
+// setGame starts a fresh tree for game and listens for its moves.
+func (this *Expectimax) setGame(game Game) {
+	// Nodes of the old tree still with the workers would be backed up into it
+	// and pushed onto the new root's frontier once they came back
+	this.waitForInFlightNodes()
+	oldRootNode := this.rootNode
+
+	this.frontier.release()
+	this.game = game
+	this.rootNode = NewBaseNode(game)
+	this.rootNode.createUserData(this.settings)
+	this.freeKeptSubtrees()
+	this.rootChanged()
+	this.moveHistory = nil
+	this.resignCount = 0
+	if this.settings.transpositions != nil {
+		this.settings.transpositionGeneration = this.settings.transpositions.clear()
+	}
+	this.moveListener = make(chan interface{}, this.moveListenerBufferSize)
+	this.pendingMoves = nil
+	game.RegisterMoveListener(this.moveListener)
+
+	if oldRootNode != nil {
+		this.cleaner.deleteTree(oldRootNode, nil)
+	}
+}
+
+// rootChanged resets what is kept about the current root. It must be called from
+// the main loop whenever the root is replaced.
+func (this *Expectimax) rootChanged() {
+	this.bestMove = nil
+	this.rootTime = time.Now()
+	this.convergence.reset()
+	this.rootChildMoves = nil
+	this.budgetReached = false
+	atomic.StoreInt32(&this.stopConditionMet, 0)
+}
+
+// AdvanceToState moves the root of the search down through moves, as if each had
+// been made on the game, keeping the subtree already searched below them. The old
+// tree is cleaned up once at the end rather than after every move. The game
+// itself isn't changed. An error is returned, leaving the root where it was, if
+// any move isn't possible on the way down, or if a position on the way can't be
+// recorded for WithMoveHistoryRecording.
+func (this *Expectimax) AdvanceToState(moves []interface{}) error {
+	var err error
+	this.runOnMainLoop(func() {
+		err = this.advance(moves)
+	})
+
+	return err
+}
+
+// Advance moves the root of the search down by move, as AdvanceToState does, and
+// returns the new root's value. A new root that hasn't been explored yet is
+// explored first, so the value is backed up from its children.
+func (this *Expectimax) Advance(move interface{}) (float64, error) {
+	var value float64
+	var err error
+	this.runOnMainLoop(func() {
+		if err = this.advance([]interface{}{move}); err != nil {
+			return
+		}
+
+		this.ensureExplored(this.rootNode)
+		value = this.rootNode.value
+	})
+
+	return value, err
+}
+
+// NotifyMove tells the Expectimax that move has been made on its game, for
+// callers driving the game without its move listeners. The root moves down as it
+// does for a move from the listener, once the main loop gets to it, or at once
+// if RunExpectimax isn't running. An illegal move is an error when it's applied
+// at once; once the main loop gets to it, it's dropped and reported as a move
+// from the listener would be.
+func (this *Expectimax) NotifyMove(move interface{}) error {
+	var moveListener chan interface{}
+	var err error
+	this.runOnMainLoop(func() {
+		if atomic.LoadInt32(&this.running) != 0 {
+			// Queue it behind any moves from the listener
+			moveListener = this.moveListener
+		} else {
+			err = this.advance([]interface{}{move})
+		}
+	})
+
+	if moveListener != nil {
+		moveListener <- move
+	}
+	return err
+}
+
+// advance moves the root down through moves. It must be called from the main
+// loop.
+func (this *Expectimax) advance(moves []interface{}) error {
+	var records []MoveRecord
+	node := this.rootNode
+	for i, move := range moves {
+		this.ensureExplored(node)
+
+		childNode, ok := node.children[move]
+		if !ok {
+			return fmt.Errorf("expectimax: move %d (%v) is not possible", i, move)
+		}
+		if this.recordMoveHistory {
+			record, err := this.newMoveRecord(node, move)
+			if err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		node = childNode
+	}
+	this.moveHistory = append(this.moveHistory, records...)
+
+	if node != this.rootNode {
+		oldRootNode := this.rootNode
+		this.trackResignation(oldRootNode.value)
+		this.frontier.release()
+		this.rootNode = oldRootNode.descendTo(node)
+		this.keepSubtrees(oldRootNode, moves[0])
+		this.cleaner.deleteTree(oldRootNode, this.rootNode)
+		this.rootChanged()
+		if this.settings.maxDepth > 0 {
+			this.rootNode.reopenDepthCuts(this.settings.maxDepth, 0)
+		}
+		if this.settings.transpositions != nil {
+			this.settings.transpositions.prune(this.rootNode)
+		}
+	}
+
+	return nil
+}
+
+// ShouldResign reports whether, with WithResignThreshold, the root's value has
+// been below the threshold each time the root has moved for the last
+// persistence moves, so the game looks lost. It's always false without it.
+func (this *Expectimax) ShouldResign() bool {
+	var resign bool
+	this.runOnMainLoop(func() {
+		resign = this.resignPersistence > 0 && this.resignCount >= this.resignPersistence
+	})
+
+	return resign
+}
+
+// trackResignation counts the re-roots in a row that leave a root valued below
+// the resign threshold. It must be called from the main loop.
+func (this *Expectimax) trackResignation(value float64) {
+	if this.resignPersistence == 0 {
+		return
+	}
+
+	if value < this.resignThreshold {
+		this.resignCount++
+	} else {
+		this.resignCount = 0
+	}
+}
+
+// MoveRecord is what the search thought of a position when a move was made from
+// it. Values are from player 0's point of view, as in GetNextMoveValues.
+type MoveRecord struct {
+	Position   Game                    // A copy of the game before the move
+	MoveValues map[interface{}]float64 // The value of each of the position's moves
+	BestMove   interface{}             // The move GetBestMove would have given, nil unless the position was the root
+	Move       interface{}             // The move made
+	Value      float64                 // The position's value
+}
+
+// MoveHistory returns a record of each move made on the game, oldest first,
+// with WithMoveHistoryRecording. When several moves are made before the search
+// catches up, the positions between them were never the root, so their records
+// have no BestMove.
+func (this *Expectimax) MoveHistory() []MoveRecord {
+	var moveHistory []MoveRecord
+	this.runOnMainLoop(func() {
+		moveHistory = append(moveHistory, this.moveHistory...)
+	})
+
+	return moveHistory
+}
+
+// newMoveRecord records node's values before move is made from it, or returns an
+// error if node's game is no longer available. It must be called from the main
+// loop.
+func (this *Expectimax) newMoveRecord(node *expectimaxNode, move interface{}) (MoveRecord, error) {
+	// GetGame already gives a copy
+	position := node.GetGame()
+	if position == nil {
+		return MoveRecord{}, fmt.Errorf("expectimax: the game before move %v is no longer available to record", move)
+	}
+
+	record := MoveRecord{Position: position, MoveValues: map[interface{}]float64{}, Move: move, Value: node.value}
+	for childMove, childNode := range node.children {
+		record.MoveValues[childMove] = childNode.value
+	}
+	if node == this.rootNode {
+		record.BestMove = this.getBestChildMove()
+	}
+
+	return record, nil
+}
+
+// keepSubtrees frees the subtrees kept from the last descent and detaches those
+// of oldRootNode's moves other than move that WithKeepSubtree asks to keep, so
+// they aren't deleted with it. It must be called from the main loop.
+func (this *Expectimax) keepSubtrees(oldRootNode *expectimaxNode, move interface{}) {
+	this.freeKeptSubtrees()
+	if this.keepSubtree == nil {
+		return
+	}
+
+	for childMove, childNode := range oldRootNode.children {
+		if childMove != move && this.keepSubtree(childMove) {
+			oldRootNode.removeChild(childMove)
+			if this.keptSubtrees == nil {
+				this.keptSubtrees = map[interface{}]*expectimaxNode{}
+			}
+			this.keptSubtrees[childMove] = childNode
+		}
+	}
+}
+
+// freeKeptSubtrees queues the subtrees kept from the last descent for deletion.
+// It must be called from the main loop.
+func (this *Expectimax) freeKeptSubtrees() {
+	for move, keptNode := range this.keptSubtrees {
+		this.cleaner.deleteTree(keptNode, nil)
+		delete(this.keptSubtrees, move)
+	}
+}
+
+// ExportKeptSubtree copies the subtree WithKeepSubtree kept for move when the
+// root last moved down, as ExportTree does, or returns nil if none was kept.
+func (this *Expectimax) ExportKeptSubtree(move interface{}, maxDepth int) *TreeNode {
+	var treeNode *TreeNode
+	this.runOnMainLoop(func() {
+		if keptNode, ok := this.keptSubtrees[move]; ok {
+			treeNode = keptNode.exportTree(move, 1.0, maxDepth, false, false)
+		}
+	})
+
+	return treeNode
+}
+
+// getUnexploredNode returns the next node to explore: the most likely unexplored
+// descendent of the root, unless some root moves are still short of the root
+// child floor, in which case they take turns, or the first node queued with
+// BreadthFirst. It must be called from the main loop.
+func (this *Expectimax) getUnexploredNode() *expectimaxNode {
+	if this.explorationOrder == BreadthFirst {
+		if this.rootNode.mostLikelyUnexploredDescendent == nil {
+			return nil // Nothing is left that the search would explore, such as below proven nodes
+		}
+		return this.frontier.next(this.rootNode)
+	}
+
+	if this.rootChildFloor > 0 {
+		if len(this.rootChildMoves) != len(this.rootNode.children) {
+			this.rootChildMoves = this.rootNode.getOrderedChildMoves()
+		}
+		for i := range this.rootChildMoves {
+			childNode := this.rootNode.children[this.rootChildMoves[(this.rootRotation+i)%len(this.rootChildMoves)]]
+			if childNode.descendentCount >= this.rootChildFloor || childNode.mostLikelyUnexploredDescendent == nil ||
+				(childNode.explorationStatus != Unexplored && childNode.explorationStatus != Archived) {
+				continue
+			}
+
+			this.rootRotation += i + 1
+			return childNode.mostLikelyUnexploredDescendent
+		}
+	}
+
+	return this.rootNode.mostLikelyUnexploredDescendent
+}
+
+// ensureExplored explores node on the main loop, or waits for the workers to
+// finish exploring it, so its children are available. It must be called from the
+// main loop.
+func (this *Expectimax) ensureExplored(node *expectimaxNode) {
+	switch node.explorationStatus {
+	case Unexplored:
+		// Unexplored and not waiting for exploration, so just explore it now
+		if node.incrementReference() {
+			node.setWaitingForExploration()
+			node.Explore(this.settings)
+			this.processExploredNode(node)
+			node.decrementReference()
+		}
+	case WaitingForExploration, Exploring:
+		for node.explorationStatus != Archived {
+			this.drainMoveListener()
+			var exploredNode *expectimaxNode
+			select {
+			case exploredNode = <-this.exploredNodeChannel:
+			case <-this.quit:
+				return // The workers have stopped, so the node won't arrive
+			}
+			this.inFlight--
+			this.processExploredNode(exploredNode)
+			exploredNode.decrementReference()
+		}
+	}
+}
+
+// RunSynchronous explores up to nodeBudget more nodes on the calling goroutine,
+// most likely first, without the workers, and returns the root's move values as
+// GetNextMoveValues would. It stops early if nothing is left to explore. With a
+// deterministic game, heuristic and child likelihood function, runs with the
+// same budget explore the same nodes, which makes it suited to comparing
+// heuristics. If RunExpectimax is running, the main loop waits while it runs.
+func (this *Expectimax) RunSynchronous(nodeBudget int) *extensions.ValueMap {
+	var nextMoveValues *extensions.ValueMap
+	this.runOnMainLoop(func() {
+		if this.moveListener == nil {
+			// Start following the game now, so RunExpectimax keeps this search
+			this.setGame(this.game)
+		}
+
+		for explored := 0; explored < nodeBudget; explored++ {
+			this.drainMoveListener()
+			node := this.getUnexploredNode()
+			if node == nil || !node.incrementReference() {
+				break
+			}
+
+			node.setWaitingForExploration()
+			node.Explore(this.settings)
+			this.processExploredNode(node)
+			node.decrementReference()
+		}
+
+		nextMoveValues = this.getNextMoveValues()
+	})
+
+	return nextMoveValues
+}
+
+// EnsureRootExplored explores the root straight away if it hasn't been yet, so
+// it has children and GetBestMove has a legal move to give from the start. If
+// the workers already have the root, it waits for them to finish with it.
+func (this *Expectimax) EnsureRootExplored() {
+	this.runOnMainLoop(func() {
+		if this.moveListener == nil {
+			// Start following the game now, so RunExpectimax keeps this root
+			this.setGame(this.game)
+		}
+		this.ensureExplored(this.rootNode)
+	})
+}
+
+// FocusOn multiplies the chance of exploring beneath the root's move by weight,
+// to search a candidate move more or less deeply than its likelihood alone
+// would. A weight of 1 removes the focus. Focus only lasts while the root does:
+// moving on to a new root clears it.
+func (this *Expectimax) FocusOn(move interface{}, weight float64) {
+	if weight < 0.0 {
+		log.Printf("expectimax: focus weight %g is negative, using 0", weight)
+		weight = 0.0
+	}
+
+	this.runOnMainLoop(func() {
+		this.rootNode.setExploreWeight(move, weight)
+		this.refocus()
+	})
+}
+
+// ClearFocus removes the focus given to every root move by FocusOn.
+func (this *Expectimax) ClearFocus() {
+	this.runOnMainLoop(func() {
+		this.rootNode.exploreWeights = nil
+		this.refocus()
+	})
+}
+
+// refocus recalculates which node is explored next once the root's explore
+// weights have changed. It must be called from the main loop.
+func (this *Expectimax) refocus() {
+	if this.rootNode.explorationStatus == Explored || this.rootNode.explorationStatus == Archived {
+		this.rootNode.calculateChildLikelihood(this.settings, false)
+	}
+}
+
+// WarmPool allocates nodes up front so the pool has count ready, moving the cost
+// of allocating them from the first search to setup. The pool is shared by every
+// Expectimax, and the garbage collector may still free nodes that sit unused in
+// it for long.
+func (this *Expectimax) WarmPool(count int) {
+	warmNodeMemoryPool(count)
+}
+
+// Pause stops new nodes being handed to the workers, leaving the tree intact.
+// Explorations already in progress are allowed to finish, and the workers then
+// wait for Resume.
+func (this *Expectimax) Pause() {
+	atomic.StoreInt32(&this.paused, 1)
+}
+
+// Resume continues a search stopped by Pause.
+func (this *Expectimax) Resume() {
+	this.runOnMainLoop(func() {
+		atomic.StoreInt32(&this.paused, 0)
+		this.wakeIdleWorkers()
+	})
+}
+
+func (this *Expectimax) IsPaused() bool {
+	return atomic.LoadInt32(&this.paused) != 0
+}
+
+// Stop shuts down RunExpectimax and its workers. The tree is left as it was.
+func (this *Expectimax) Stop() {
+	this.stopOnce.Do(func() {
+		close(this.quit)
+	})
+}
+
+func (this *Expectimax) isStopped() bool {
+	select {
+	case <-this.quit:
+		return true
+	default:
+		return false
 	}
 }
 
 const expectimaxWorkerCount int = 10
 
+const (
+	defaultResponsePollInterval = 100 * time.Millisecond
+	minResponsePollInterval     = time.Millisecond
+	defaultMinResponseFraction  = 0.01
+	defaultMaxNodeCount         = 100000
+)
+
+// defaultExploredNodeBufferSize is how many explored nodes can wait for the main
+// loop before workers block.
+const defaultExploredNodeBufferSize int = 10 * expectimaxWorkerCount
+
+// defaultMoveListenerBufferSize is how many moves the game can make without
+// waiting for the main loop.
+const defaultMoveListenerBufferSize int = 4
+
+// RunExpectimax runs the search on the calling goroutine until Stop is called or
+// the game ends. The main loop only ever runs once, so it returns straight away
+// if it has already been started, by Search or an earlier call.
 func (this *Expectimax) RunExpectimax() {
-	this.rootNode = NewBaseNode(this.game)
+	if this.startMainLoop() {
+		this.runMainLoop()
+	}
+}
+
+// startMainLoop claims the main loop's one run, marking it running so requests
+// queue for it rather than running inline. It reports whether the caller should
+// go on to run the loop.
+func (this *Expectimax) startMainLoop() bool {
+	if !atomic.CompareAndSwapInt32(&this.started, 0, 1) {
+		return false
+	}
 
-	moveListener := make(chan interface{}, 4)
-	this.game.RegisterMoveListener(moveListener)
+	this.inlineLock.Lock()
+	atomic.StoreInt32(&this.running, 1)
+	this.inlineLock.Unlock()
+	return true
+}
+
+func (this *Expectimax) runMainLoop() {
+	defer close(this.mainLoopDone)
+	defer atomic.StoreInt32(&this.running, 0)
+
+	if this.moveListener == nil {
+		this.setGame(this.game)
+	}
 
 	this.unexploredNodeReceiverChannel = make(chan chan<- *expectimaxNode, expectimaxWorkerCount)
-	this.exploredNodeChannel = make(chan *expectimaxNode, 10*expectimaxWorkerCount)
-	exploreNodeWorkers := make([]*exploreNodeWorker, 0, expectimaxWorkerCount)
+	this.exploredNodeChannel = make(chan *expectimaxNode, this.exploredNodeBufferSize)
 
 	for i := 0; i < expectimaxWorkerCount; i++ {
-		exploreNodeWorker := NewExploreNodeWorker(this.unexploredNodeReceiverChannel, this.exploredNodeChannel)
-		exploreNodeWorkers = append(exploreNodeWorkers, exploreNodeWorker)
-		go exploreNodeWorker.ExploreNodeThread(this.heuristic, this.calculateChildLikelihood)
-	}
-
-	exploreNodeCount := 0
-	if this.printDebugMessages {
-		go func() {
-			lastExploreCount := 0
-			for {
-				time.Sleep(time.Second)
-				if exploreNodeCount != 0 || lastExploreCount != 0 {
-					fmt.Printf("Explore Count: %d. Waiting workers: %d. Allocated nodes: %d. Expected result: %g\n", exploreNodeCount, len(this.unexploredNodeReceiverChannel), this.rootNode.descendentCount, this.rootNode.value)
-				}
-				lastExploreCount = exploreNodeCount
-				exploreNodeCount = 0
-			}
-		}()
+		exploreNodeWorker := NewExploreNodeWorker(i, this.unexploredNodeReceiverChannel, this.exploredNodeChannel, this.quit)
+		if this.workerStats != nil {
+			exploreNodeWorker.stats = this.workerStats[i]
+		}
+		go exploreNodeWorker.ExploreNodeThread(this.settings)
 	}
 
+	if this.watchdogTimeout > 0 {
+		go this.runWatchdog()
+	}
+
+	go func() {
+		lastExploredNodes := this.stats.getExploredNodes()
+		lastExploreCount := int64(0)
+		for {
+			select {
+			case <-time.After(time.Second):
+			case <-this.quit:
+				return
+			}
+			this.stats.sample(time.Now())
+
+			exploredNodes := this.stats.getExploredNodes()
+			exploreCount := exploredNodes - lastExploredNodes
+			if this.printDebugMessages && (exploreCount != 0 || lastExploreCount != 0) {
+				fmt.Printf("Explore Count: %d. Nodes per second: %.0f. Waiting workers: %d. Allocated nodes: %d. Expected result: %g\n", exploreCount, this.NodesPerSecond(), len(this.unexploredNodeReceiverChannel)+int(atomic.LoadInt32(&this.idleWorkerCount)), this.rootNode.descendentCount, this.rootNode.value)
+			}
+			lastExploredNodes = exploredNodes
+			lastExploreCount = exploreCount
+		}
+	}()
+
 	for {
+		if this.defaultDeadline > 0 && time.Since(this.rootTime) >= this.defaultDeadline {
+			atomic.StoreInt32(&this.stopConditionMet, 1)
+		}
+
+		if len(this.pendingMoves) > 0 {
+			this.applyPendingMoves()
+		}
+
+		parked := false
 		select {
-		case move := <-moveListener:
+		case move := <-this.moveListener:
 			if move == nil {
 				break
 			}
 
-			// Ensure rootNode has been explored
-			switch this.rootNode.explorationStatus {
-			case Unexplored:
-				// Unexplored and not waiting for exploration, so just explore it now
-				this.rootNode.Explore(this.heuristic, this.calculateChildLikelihood)
-				this.rootNode.processExploredNode(this.calculateChildLikelihood)
-			case WaitingForExploration, Exploring:
-				for this.rootNode.explorationStatus != Archived {
-					exploredNode := <-this.exploredNodeChannel
-					exploredNode.processExploredNode(this.calculateChildLikelihood)
-					exploredNode.decrementReference()
-				}
+			this.pendingMoves = append(this.pendingMoves, move)
+			this.applyPendingMoves()
+
+		case exploredNode := <-this.exploredNodeChannel:
+			// Back up any other nodes already explored in one batch
+			exploredNodes := []*expectimaxNode{exploredNode}
+			for len(this.exploredNodeChannel) > 0 {
+				exploredNodes = append(exploredNodes, <-this.exploredNodeChannel)
 			}
 
-			this.rootNode = this.rootNode.descendToChild(move)
+			this.inFlight -= len(exploredNodes)
+			this.processExploredNodes(exploredNodes)
+			for _, exploredNode := range exploredNodes {
+				go exploredNode.decrementReference()
+			}
 
-			if this.rootNode.game.IsGameOver() {
+		case bestMoveRequest := <-this.bestMoveChannelReceiver:
+			if bestMoveRequest.abandoned() {
 				break
 			}
 
-		case exploredNode := <-this.exploredNodeChannel:
-			exploreNodeCount++
-			exploredNode.processExploredNode(this.calculateChildLikelihood)
-			go exploredNode.decrementReference()
-
-		case bestMoveChannel := <-this.bestMoveChannelReceiver:
-			if len(moveListener) > 0 {
+			if this.hasPendingMoves() {
 				// If there are moves to be processed, do those first
-				this.bestMoveChannelReceiver <- bestMoveChannel
+				this.requeueBestMoveRequest(bestMoveRequest, 0)
 				break
 			}
 
-			this.sendBestMove(bestMoveChannel)
+			this.sendBestMove(bestMoveRequest)
 
 		case nextMoveChannel := <-this.nextMoveChannelReceiver:
-			if len(moveListener) > 0 {
+			if this.hasPendingMoves() {
 				// If there are moves to be processed, do those first
-				this.nextMoveChannelReceiver <- nextMoveChannel
+				this.requeueNextMoveRequest(nextMoveChannel, 0)
 				break
 			}
 
-			if this.rootNode.descendentCount < this.maxNodeCount/100 && this.rootNode.mostLikelyUnexploredDescendent != nil && this.IsCurrentlySearching() {
+			if !this.isDeepEnough() && this.isCurrentlySearching() {
 				// Wait for more depth to be explored
-				go func() {
-					time.Sleep(time.Duration(100) * time.Millisecond)
-					this.nextMoveChannelReceiver <- nextMoveChannel
-				}()
+				this.requeueNextMoveRequest(nextMoveChannel, this.responsePollInterval)
 			} else {
-				nextMoveMap := extensions.ValueMap{}
-				for childMove, childNode := range this.rootNode.children {
-					nextMoveMap[childMove] = childNode.value
-				}
-
-				nextMoveChannel <- &nextMoveMap
+				nextMoveChannel <- this.getNextMoveValues()
 			}
 
+		case request := <-this.requestChannel:
+			request()
+
+		case <-this.quit:
+			return
+
 		case unexploredNodeReceiver := <-this.unexploredNodeReceiverChannel:
-			unexploredNode := this.rootNode.mostLikelyUnexploredDescendent
-			if unexploredNode != nil && this.rootNode.descendentCount < this.maxNodeCount {
+			if this.IsPaused() {
+				// Park the worker until Resume rather than cycling it through the loop
+				this.parkWorker(unexploredNodeReceiver)
+				parked = true
+				break
+			}
+
+			unexploredNode := this.getUnexploredNode()
+			if unexploredNode != nil && this.rootNode.descendentCount >= this.maxNodeCount {
+				unexploredNode.skipReason = SkippedForBudget
+				this.reportBudgetReached()
+			}
+
+			if unexploredNode != nil && this.rootNode.descendentCount < this.maxNodeCount && atomic.LoadInt32(&this.stopConditionMet) == 0 {
 				if !unexploredNode.incrementReference() { // This will be decremenented once it's processed out of exploredNodeChannel
 					continue
 				}
@@ -185,52 +1669,165 @@ func (this *Expectimax) RunExpectimax() {
 				unexploredNode.setWaitingForExploration()
 
 				unexploredNodeReceiver <- unexploredNode
+				this.inFlight++
+			} else if unexploredNode == nil && this.exhaustedPolicy == StopWhenExhausted {
+				// The tree is exact, so park the worker until there's more to explore
+				this.parkWorker(unexploredNodeReceiver)
+				parked = true
 			} else {
-				time.Sleep(time.Duration(1) * time.Millisecond)
+				// Hand the receiver back before sleeping so an idle search reports all workers waiting
 				this.unexploredNodeReceiverChannel <- unexploredNodeReceiver
+				time.Sleep(time.Duration(1) * time.Millisecond)
 			}
 		}
 
+		// Anything but parking a worker may have given the parked ones work. They're
+		// woken without looking at the tree, which a worker may be exploring.
+		if len(this.idleWorkers) > 0 && !parked && !this.IsPaused() {
+			this.wakeIdleWorkers()
+		}
+
 		if this.rootNode.game.IsGameOver() {
 			break
 		}
 	}
 
-	for _, worker := range exploreNodeWorkers {
-		worker.terminate = true
+	this.Stop()
+}
+
+// drainMoveListener takes the moves waiting on moveListener into pendingMoves,
+// so the game isn't left blocked on a full listener while the main loop is busy
+// with a long step. It must be called from the main loop.
+func (this *Expectimax) drainMoveListener() {
+	for len(this.moveListener) > 0 {
+		if move := <-this.moveListener; move != nil {
+			this.pendingMoves = append(this.pendingMoves, move)
+		}
 	}
 }
 
-func NewExpectimax(game Game, heuristic ExpectimaxHeuristic, calculateChildLikelihood ExpectimaxChildLikelihoodFunc, maxNodeCount int) *Expectimax {
-	initNodeMemoryPool()
+// hasPendingMoves reports whether the game has made moves the root hasn't
+// followed yet. It must be called from the main loop.
+func (this *Expectimax) hasPendingMoves() bool {
+	return len(this.pendingMoves) > 0 || len(this.moveListener) > 0
+}
 
-	return &Expectimax{
-		game,
-		heuristic,
-		calculateChildLikelihood,
-		NewBaseNode(game),
-		make(chan (chan<- interface{}), 10),
-		make(chan (chan<- *extensions.ValueMap), 10),
-		nil,
-		nil,
-		maxNodeCount,
-		false,
+// applyPendingMoves moves the root down through every move the game has made,
+// in one step. Moves that can't be followed from the root are dropped, leaving
+// the root where it was, and reported. It must be called from the main loop.
+func (this *Expectimax) applyPendingMoves() {
+	this.drainMoveListener()
+	moves := this.pendingMoves
+	this.pendingMoves = nil
+	if err := this.advance(moves); err != nil {
+		this.reportError(fmt.Errorf("expectimax: dropped moves %v: %v", moves, err))
 	}
 }
 
-func NewDebugExpectimax(game Game, heuristic ExpectimaxHeuristic, calculateChildLikelihood ExpectimaxChildLikelihoodFunc, maxNodeCount int) *Expectimax {
+// reportBudgetReached fires onBudgetReached the first time the search of the
+// current root turns work away for want of budget. It must be called from the
+// main loop.
+func (this *Expectimax) reportBudgetReached() {
+	if this.onBudgetReached == nil || this.budgetReached {
+		return
+	}
+
+	this.budgetReached = true
+	this.onBudgetReached(this.getSearchStats())
+}
+
+// parkWorker holds on to the receiver of a worker that's been given nothing to
+// explore, until wakeIdleWorkers. It must be called from the main loop.
+func (this *Expectimax) parkWorker(unexploredNodeReceiver chan<- *expectimaxNode) {
+	this.idleWorkers = append(this.idleWorkers, unexploredNodeReceiver)
+	atomic.AddInt32(&this.idleWorkerCount, 1)
+}
+
+// wakeIdleWorkers hands the receivers of parked workers back so they can be given
+// nodes again. A worker with nothing to explore is just parked again. It must be
+// called from the main loop.
+func (this *Expectimax) wakeIdleWorkers() {
+	for _, unexploredNodeReceiver := range this.idleWorkers {
+		this.unexploredNodeReceiverChannel <- unexploredNodeReceiver
+	}
+	this.idleWorkers = this.idleWorkers[:0]
+	atomic.StoreInt32(&this.idleWorkerCount, 0)
+}
+
+func newExpectimax(game Game, heuristic ExpectimaxHeuristic, calculateChildLikelihood ExpectimaxChildLikelihoodFunc, maxNodeCount int, printDebugMessages bool, options []ExpectimaxOption) *Expectimax {
 	initNodeMemoryPool()
 
-	return &Expectimax{
-		game,
-		heuristic,
-		calculateChildLikelihood,
-		NewBaseNode(game),
-		make(chan (chan<- interface{}), 10),
-		make(chan (chan<- *extensions.ValueMap), 10),
-		nil,
-		nil,
-		maxNodeCount,
-		true,
+	if heuristic == nil {
+		log.Printf("expectimax: no heuristic given, using ZeroHeuristic")
+		heuristic = ZeroHeuristic
+	}
+	if calculateChildLikelihood == nil {
+		log.Printf("expectimax: no child likelihood function given, using FirstChildLikelihood")
+		calculateChildLikelihood = FirstChildLikelihood
+	}
+
+	if maxNodeCount <= 0 {
+		log.Printf("expectimax: max node count %d is not positive, using %d", maxNodeCount, defaultMaxNodeCount)
+		maxNodeCount = defaultMaxNodeCount
+	}
+
+	expectimax := &Expectimax{
+		game:                    game,
+		settings:                &searchSettings{heuristic: heuristic, calculateChildLikelihood: calculateChildLikelihood, heuristicCalls: new(int64), heuristicStats: &heuristicValueStatistics{}},
+		rootNode:                NewBaseNode(game),
+		bestMoveChannelReceiver: make(chan bestMoveRequest, 10),
+		nextMoveChannelReceiver: make(chan (chan<- *extensions.ValueMap), 10),
+		requestChannel:          make(chan func(), 10),
+		quit:                    make(chan struct{}),
+		mainLoopDone:            make(chan struct{}),
+		maxNodeCount:            maxNodeCount,
+		minResponseFraction:     defaultMinResponseFraction,
+		minResponseNodes:        -1,
+		exploredNodeBufferSize:  defaultExploredNodeBufferSize,
+		moveListenerBufferSize:  defaultMoveListenerBufferSize,
+		rand:                    newDefaultRand(),
+		convergence:             newConvergenceTracker(defaultConvergenceWindow, defaultConvergenceThreshold),
+		printDebugMessages:      printDebugMessages,
+		responsePollInterval:    defaultResponsePollInterval,
 	}
+
+	for _, option := range options {
+		option(expectimax)
+	}
+	expectimax.rootNode.createUserData(expectimax.settings)
+
+	expectimax.rootTime = time.Now()
+	expectimax.stats.sample(expectimax.rootTime)
+
+	if expectimax.exploreRootOnCreate {
+		expectimax.EnsureRootExplored()
+	}
+
+	return expectimax
+}
+
+// NewExpectimaxFromRoot is NewExpectimax with the search tree already built as
+// root describes, rather than just the root, so a known tree can be set up for
+// tests. The moves must be moves of game, along each line, for GetGame to
+// replay. Values are backed up from the leaves' heuristics with
+// calculateChildLikelihood, as the search would. Like EnsureRootExplored, it
+// starts following the game, so RunExpectimax keeps the tree.
+func NewExpectimaxFromRoot(game Game, root *TreeSpec, heuristic ExpectimaxHeuristic, calculateChildLikelihood ExpectimaxChildLikelihoodFunc, maxNodeCount int, options ...ExpectimaxOption) *Expectimax {
+	expectimax := newExpectimax(game, heuristic, calculateChildLikelihood, maxNodeCount, false, options)
+	expectimax.runOnMainLoop(func() {
+		if expectimax.moveListener == nil {
+			expectimax.setGame(expectimax.game)
+		}
+		expectimax.rootNode.buildTree(expectimax.settings, root)
+	})
+
+	return expectimax
+}
+
+func NewExpectimax(game Game, heuristic ExpectimaxHeuristic, calculateChildLikelihood ExpectimaxChildLikelihoodFunc, maxNodeCount int, options ...ExpectimaxOption) *Expectimax {
+	return newExpectimax(game, heuristic, calculateChildLikelihood, maxNodeCount, false, options)
+}
+
+func NewDebugExpectimax(game Game, heuristic ExpectimaxHeuristic, calculateChildLikelihood ExpectimaxChildLikelihoodFunc, maxNodeCount int, options ...ExpectimaxOption) *Expectimax {
+	return newExpectimax(game, heuristic, calculateChildLikelihood, maxNodeCount, true, options)
 }
@@ -1,7 +1,15 @@
 package expectimax
 
 import (
+	"context"
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/andrew-j-armstrong/go-extensions"
 )
@@ -9,11 +17,11 @@ import (
 func TestGetBestMove(t *testing.T) {
 	t.Run("test GetBestMove()", func(t *testing.T) {
 		dummyMove := &struct{}{}
-		expectimax := Expectimax{nil, nil, nil, nil, make(chan (chan<- interface{})), nil, 0}
+		expectimax := Expectimax{bestMoveChannelReceiver: make(chan bestMoveRequest)}
 
 		go func() {
-			bestMoveChannel := <-expectimax.bestMoveChannelReceiver
-			bestMoveChannel <- dummyMove
+			request := <-expectimax.bestMoveChannelReceiver
+			request.reply <- dummyMove
 		}()
 
 		if expectimax.GetBestMove() != dummyMove {
@@ -22,10 +30,50 @@ func TestGetBestMove(t *testing.T) {
 	})
 }
 
+func TestGetBestMoveContext(t *testing.T) {
+	t.Run("test an abandoned request stops being retried", func(t *testing.T) {
+		initNodeMemoryPool()
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 100000,
+			WithMinResponseNodes(1000), WithResponsePollInterval(5*time.Millisecond))
+		defer expectimax.Stop()
+
+		// Paused with only the root explored, the search is never deep enough
+		expectimax.EnsureRootExplored()
+		expectimax.Pause()
+		go expectimax.RunExpectimax()
+		time.Sleep(20 * time.Millisecond)
+		goroutines := runtime.NumGoroutine()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		bestMove, err := expectimax.GetBestMoveContext(ctx)
+		if err != context.DeadlineExceeded {
+			t.Errorf("GetBestMoveContext() returned error %v, expected the deadline to be exceeded.", err)
+		}
+		if bestMove == nil {
+			t.Errorf("GetBestMoveContext() returned no move, expected the best as it stands.")
+		}
+
+		// The retries come and go, so the count must stay down for a while
+		settled := func() bool {
+			for i := 0; i < 20; i++ {
+				if runtime.NumGoroutine() > goroutines {
+					return false
+				}
+				time.Sleep(time.Millisecond)
+			}
+			return true
+		}
+		if !waitFor(time.Second, settled) {
+			t.Errorf("%d goroutines were running after the request was abandoned, expected %d as before it.", runtime.NumGoroutine(), goroutines)
+		}
+	})
+}
+
 func TestGetNextMoveValues(t *testing.T) {
 	t.Run("test GetNextMoveValues()", func(t *testing.T) {
 		dummyMap := extensions.ValueMap{}
-		expectimax := Expectimax{nil, nil, nil, nil, nil, make(chan (chan<- *extensions.ValueMap)), 0}
+		expectimax := Expectimax{nextMoveChannelReceiver: make(chan (chan<- *extensions.ValueMap))}
 
 		go func() {
 			nextMoveChannel := <-expectimax.nextMoveChannelReceiver
@@ -37,3 +85,2875 @@ func TestGetNextMoveValues(t *testing.T) {
 		}
 	})
 }
+
+func TestAlternatingPerspective(t *testing.T) {
+	t.Run("test WithAlternatingPerspective() picks the forced win", func(t *testing.T) {
+		root := playerBranch(0, 0.0,
+			playerBranch(1, 0.9, leaf(1.0), leaf(-1.0)),
+			playerBranch(1, 0.1, leaf(1.0), leaf(1.0)),
+		)
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000, WithAlternatingPerspective(true))
+		exploreAll(expectimax)
+
+		if value := expectimax.rootNode.children[0].value; value != -1.0 {
+			t.Errorf("Opponent node value was %g, expected -1.", value)
+		}
+
+		if expectimax.rootNode.value != 1.0 {
+			t.Errorf("Root value was %g, expected 1.", expectimax.rootNode.value)
+		}
+
+		bestMoveChannel := make(chan interface{}, 1)
+		expectimax.sendBestMove(bestMoveRequest{reply: bestMoveChannel})
+		if bestMove := <-bestMoveChannel; bestMove != 1 {
+			t.Errorf("Best move was %v, expected 1.", bestMove)
+		}
+	})
+}
+
+func TestWalk(t *testing.T) {
+	root := branch(0.0,
+		branch(1.0, leaf(2.0), leaf(3.0)),
+		branch(4.0, leaf(5.0)),
+		leaf(6.0),
+	)
+
+	t.Run("test Walk() visits every node", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		visited := 0
+		expectimax.Walk(func(depth int, move interface{}, value, heuristic float64, status string) bool {
+			if depth == 0 && move != nil {
+				t.Errorf("Root visited with move %v, expected nil.", move)
+			}
+			visited++
+			return true
+		})
+
+		if visited != expectimax.rootNode.descendentCount+1 {
+			t.Errorf("Walk() visited %d nodes, expected %d.", visited, expectimax.rootNode.descendentCount+1)
+		}
+	})
+
+	t.Run("test Walk() stops early", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		visited := 0
+		expectimax.Walk(func(depth int, move interface{}, value, heuristic float64, status string) bool {
+			visited++
+			return visited < 3
+		})
+
+		if visited != 3 {
+			t.Errorf("Walk() visited %d nodes after being told to stop at 3.", visited)
+		}
+	})
+}
+
+func TestOnNodeCreate(t *testing.T) {
+	var created int64
+	nextID := func() interface{} { return atomic.AddInt64(&created, 1) }
+
+	t.Run("test user data set at creation is visible in a walk", func(t *testing.T) {
+		atomic.StoreInt64(&created, 0)
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 2)), testHeuristic, uniformChildLikelihood, 1000, WithOnNodeCreate(nextID))
+		exploreAll(expectimax)
+
+		seen := map[interface{}]bool{}
+		expectimax.WalkUserData(func(depth int, move interface{}, value, heuristic float64, status string, userData interface{}) bool {
+			if userData == nil || seen[userData] {
+				t.Errorf("Node at depth %d after move %v had user data %v, expected an ID of its own.", depth, move, userData)
+			}
+			seen[userData] = true
+			return true
+		})
+		if int64(len(seen)) != atomic.LoadInt64(&created) || len(seen) != 7 {
+			t.Errorf("Walk saw %d IDs of %d created, expected all 7 nodes to have one.", len(seen), atomic.LoadInt64(&created))
+		}
+		if userData := expectimax.ExportTree(-1).Children[0].UserData; userData == nil {
+			t.Errorf("ExportTree() didn't carry the user data.")
+		}
+	})
+
+	t.Run("test reset clears the user data", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 1)), testHeuristic, uniformChildLikelihood, 1000, WithOnNodeCreate(nextID))
+		exploreAll(expectimax)
+
+		childNode := expectimax.rootNode.children[0]
+		childNode.reset()
+		if childNode.userData != nil {
+			t.Errorf("User data %v was left on a reset node.", childNode.userData)
+		}
+	})
+}
+
+func TestOnExplore(t *testing.T) {
+	t.Run("test WithOnExplore() fires once per explored node", func(t *testing.T) {
+		root := branch(0.0,
+			branch(1.0, leaf(2.0), leaf(3.0)),
+			leaf(4.0),
+		)
+
+		calls := 0
+		onExplore := func(move interface{}, childCount int, value float64) {
+			calls++
+		}
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000, WithOnExplore(onExplore))
+		exploreAll(expectimax)
+
+		if calls != 2 {
+			t.Errorf("OnExplore fired %d times, expected once for each of the 2 unfinished nodes.", calls)
+		}
+	})
+}
+
+func TestNodesPerSecond(t *testing.T) {
+	t.Run("test NodesPerSecond() tracks the explore rate", func(t *testing.T) {
+		root := branch(0.0)
+		for i := 0; i < 20; i++ {
+			root.children = append(root.children, branch(0.0, leaf(0.0)))
+		}
+
+		slowHeuristic := func(game Game) float64 {
+			time.Sleep(time.Millisecond)
+			return testHeuristic(game)
+		}
+
+		start := time.Now()
+		expectimax := NewExpectimax(newTestGame(root), slowHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+		elapsed := time.Since(start).Seconds()
+
+		nodesPerSecond := expectimax.NodesPerSecond()
+		expected := float64(expectimax.stats.getExploredNodes()) / elapsed
+		if nodesPerSecond <= 0.0 || nodesPerSecond > 1.5*expected || nodesPerSecond < 0.5*expected {
+			t.Errorf("NodesPerSecond() returned %g, expected roughly %g.", nodesPerSecond, expected)
+		}
+	})
+}
+
+func TestElapsedSinceRoot(t *testing.T) {
+	t.Run("test the elapsed time restarts when the root moves", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 3)), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+		time.Sleep(50 * time.Millisecond)
+
+		if elapsed := expectimax.ElapsedSinceRoot(); elapsed < 50*time.Millisecond {
+			t.Errorf("ElapsedSinceRoot() was %v, expected at least 50ms.", elapsed)
+		}
+
+		if err := expectimax.AdvanceToState([]interface{}{0}); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := expectimax.ElapsedSinceRoot(); elapsed >= 50*time.Millisecond {
+			t.Errorf("ElapsedSinceRoot() was %v just after the root moved, expected it to restart.", elapsed)
+		}
+	})
+}
+
+func TestResetStats(t *testing.T) {
+	t.Run("test ResetStats() zeroes the counters", func(t *testing.T) {
+		root := branch(0.0, branch(0.0, leaf(0.0), leaf(0.0)), leaf(0.0))
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+
+		exploreNode := expectimax.rootNode
+		exploreNode.incrementReference()
+		exploreNode.setWaitingForExploration()
+		exploreNode.Explore(expectimax.settings)
+		expectimax.processExploredNode(exploreNode)
+		exploreNode.decrementReference()
+
+		expectimax.ResetStats()
+		if count := expectimax.stats.getExploredNodes(); count != 0 {
+			t.Errorf("Explored node count was %d after ResetStats(), expected 0.", count)
+		}
+		if nodesPerSecond := expectimax.NodesPerSecond(); nodesPerSecond != 0.0 {
+			t.Errorf("NodesPerSecond() was %g after ResetStats(), expected 0.", nodesPerSecond)
+		}
+		if calls := expectimax.HeuristicCalls(); calls != 0 {
+			t.Errorf("HeuristicCalls() was %d after ResetStats(), expected 0.", calls)
+		}
+
+		exploreAll(expectimax)
+		if count := expectimax.stats.getExploredNodes(); count != 1 {
+			t.Errorf("Explored node count was %d after continuing the search, expected 1.", count)
+		}
+	})
+}
+
+func TestHeuristicCalls(t *testing.T) {
+	t.Run("test exploring a node with c children calls the heuristic c times", func(t *testing.T) {
+		root := branch(0.0, branch(0.0, leaf(0.0)), branch(0.0, leaf(0.0)), branch(0.0, leaf(0.0)))
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+
+		exploreSteps(expectimax, 1)
+		if calls := expectimax.HeuristicCalls(); calls != 3 {
+			t.Errorf("HeuristicCalls() was %d after exploring a node with 3 children, expected 3.", calls)
+		}
+		if nodeCount := expectimax.GetNodeCount(); nodeCount != 3 {
+			t.Errorf("GetNodeCount() was %d, expected 3.", nodeCount)
+		}
+	})
+}
+
+func TestHeuristicValueStats(t *testing.T) {
+	t.Run("test HeuristicValueStats() reports the heuristic's outputs", func(t *testing.T) {
+		root := branch(0.0, leaf(-2.0), leaf(0.0), leaf(4.0))
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		if stats := expectimax.HeuristicValueStats(); stats != (ValueStats{}) {
+			t.Errorf("HeuristicValueStats() was %+v before searching, expected zero.", stats)
+		}
+
+		exploreAll(expectimax)
+
+		stats := expectimax.HeuristicValueStats()
+		if stats.Min != -2.0 || stats.Max != 4.0 {
+			t.Errorf("HeuristicValueStats() range was [%g, %g], expected [-2, 4].", stats.Min, stats.Max)
+		}
+		if math.Abs(stats.Mean-2.0/3.0) > 1e-9 || math.Abs(stats.StdDev-math.Sqrt(56.0/9.0)) > 1e-9 {
+			t.Errorf("HeuristicValueStats() mean and standard deviation were %g and %g, expected 2/3 and sqrt(56/9).", stats.Mean, stats.StdDev)
+		}
+	})
+}
+
+func TestSearch(t *testing.T) {
+	t.Run("test Search() returns a populated result", func(t *testing.T) {
+		root := branch(0.0,
+			branch(1.0, leaf(2.0), leaf(-3.0)),
+			branch(-1.0, leaf(1.0), leaf(1.5)),
+		)
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000, WithSearchTimeout(5*time.Second))
+		defer expectimax.Stop()
+
+		result := expectimax.Search()
+
+		if result.BestMove != 0 {
+			t.Errorf("Search() best move was %v, expected 0.", result.BestMove)
+		}
+		if result.Value != 2.0 {
+			t.Errorf("Search() value was %g, expected 2.", result.Value)
+		}
+		if result.NodeCount != 6 {
+			t.Errorf("Search() node count was %d, expected 6.", result.NodeCount)
+		}
+		if result.AverageDepth <= 0.0 {
+			t.Errorf("Search() average depth was %g, expected it to be positive.", result.AverageDepth)
+		}
+		if len(result.PrincipalVariation) != 2 || result.PrincipalVariation[0] != 0 || result.PrincipalVariation[1] != 0 {
+			t.Errorf("Search() principal variation was %v, expected [0 0].", result.PrincipalVariation)
+		}
+	})
+
+	t.Run("test Search() after Stop() returns the result without restarting", func(t *testing.T) {
+		root := branch(0.0,
+			branch(1.0, leaf(2.0), leaf(-3.0)),
+			branch(-1.0, leaf(1.0), leaf(1.5)),
+		)
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000, WithSearchTimeout(5*time.Second))
+		first := expectimax.Search()
+		expectimax.Stop()
+
+		if second := expectimax.Search(); !reflect.DeepEqual(first, second) {
+			t.Errorf("Search() after Stop() returned %+v, expected the first result %+v.", second, first)
+		}
+		done := make(chan struct{})
+		go func() {
+			expectimax.RunExpectimax()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Errorf("RunExpectimax() after Stop() didn't return.")
+		}
+	})
+
+	t.Run("test Search() after the game ends returns the result", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(leaf(3.0)), testHeuristic, maxChildLikelihood, 1000, WithSearchTimeout(5*time.Second))
+		defer expectimax.Stop()
+		expectimax.Search()
+		if !waitFor(5*time.Second, func() bool { return expectimax.StopReason() == Stopped }) {
+			t.Fatalf("The search didn't stop at the end of the game.")
+		}
+
+		if result := expectimax.Search(); result.NodeCount != 0 {
+			t.Errorf("Search() after the game ended found %d nodes, expected 0.", result.NodeCount)
+		}
+	})
+}
+
+func TestGetBestMoveN(t *testing.T) {
+	t.Run("test GetBestMoveN() ranks moves by descending value", func(t *testing.T) {
+		root := branch(0.0, leaf(1.0), leaf(3.0), leaf(-2.0), leaf(2.0))
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		bestMoves := expectimax.GetBestMoveN(3)
+		expected := []MoveValue{{1, 3.0}, {3, 2.0}, {0, 1.0}}
+		if len(bestMoves) != len(expected) {
+			t.Fatalf("GetBestMoveN(3) returned %v, expected %v.", bestMoves, expected)
+		}
+		for i := range expected {
+			if bestMoves[i] != expected[i] {
+				t.Errorf("GetBestMoveN(3) returned %v, expected %v.", bestMoves, expected)
+				break
+			}
+		}
+
+		if count := len(expectimax.GetBestMoveN(10)); count != 4 {
+			t.Errorf("GetBestMoveN(10) returned %d moves, expected all 4.", count)
+		}
+	})
+
+	t.Run("test GetBestMoveN() waits for the minimum response nodes", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000,
+			WithMinResponseNodes(200), WithResponsePollInterval(time.Millisecond))
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if bestMoves := expectimax.GetBestMoveN(2); len(bestMoves) != 2 {
+			t.Errorf("GetBestMoveN(2) returned %v, expected 2 moves.", bestMoves)
+		}
+		if nodeCount := expectimax.GetNodeCount(); nodeCount < 200 {
+			t.Errorf("GetBestMoveN() answered with %d nodes, expected at least 200.", nodeCount)
+		}
+	})
+}
+
+func TestExpectedGameLength(t *testing.T) {
+	t.Run("test ExpectedGameLength() is the principal variation length of a fully explored game", func(t *testing.T) {
+		root := branch(0.0,
+			branch(1.0, branch(1.0, leaf(2.0)), leaf(-3.0)),
+			branch(-1.0, leaf(1.0), leaf(1.5)),
+		)
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		if length := expectimax.ExpectedGameLength(); length != 0.0 {
+			t.Errorf("ExpectedGameLength() was %g before exploring, expected 0.", length)
+		}
+
+		exploreAll(expectimax)
+
+		if length := expectimax.ExpectedGameLength(); length != 3.0 {
+			t.Errorf("ExpectedGameLength() was %g, expected 3 for principal variation %v.", length, expectimax.GetPrincipalVariation())
+		}
+	})
+}
+
+func TestGetMaxExploredDepth(t *testing.T) {
+	t.Run("test GetMaxExploredDepth() follows a deep narrow branch", func(t *testing.T) {
+		deepBranch := leaf(0.0)
+		for i := 0; i < 5; i++ {
+			deepBranch = branch(0.0, deepBranch)
+		}
+		root := branch(0.0, deepBranch, uniformTree(2, 1), leaf(0.0))
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		if depth := expectimax.GetMaxExploredDepth(); depth != 0 {
+			t.Errorf("GetMaxExploredDepth() was %d before searching, expected 0.", depth)
+		}
+
+		exploreSteps(expectimax, 1)
+		if depth := expectimax.GetMaxExploredDepth(); depth != 1 {
+			t.Errorf("GetMaxExploredDepth() was %d after exploring the root, expected 1.", depth)
+		}
+
+		exploreAll(expectimax)
+		if depth := expectimax.GetMaxExploredDepth(); depth != 6 {
+			t.Errorf("GetMaxExploredDepth() was %d, expected the deep branch's 6.", depth)
+		}
+	})
+}
+
+func TestGameAt(t *testing.T) {
+	root := branch(0.0,
+		branch(1.0, leaf(2.0), leaf(-3.0)),
+		branch(-1.0, leaf(1.0), leaf(1.5)),
+	)
+
+	t.Run("test GameAt() returns the state reached by a two-move path", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		game, err := expectimax.GameAt([]interface{}{1, 0})
+		if err != nil {
+			t.Fatalf("GameAt() failed: %v", err)
+		}
+		if state := game.(testStateGame).state(); state != root.children[1].children[0] {
+			t.Errorf("GameAt() returned state %v, expected the state after moves 1 and 0.", state)
+		}
+	})
+
+	t.Run("test GameAt() fails for a path leaving the searched tree", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreSteps(expectimax, 1)
+
+		if _, err := expectimax.GameAt([]interface{}{1, 0}); err == nil {
+			t.Errorf("GameAt() succeeded for an unexplored node's child.")
+		}
+	})
+}
+
+func TestTerminalValue(t *testing.T) {
+	t.Run("test TerminalValue() backs up through a forced win", func(t *testing.T) {
+		root := branch(0.0,
+			branch(0.0, branch(0.0, leaf(1.0))),
+			branch(0.0, leaf(-1.0), leaf(0.0)),
+		)
+
+		roughHeuristic := func(game Game) float64 {
+			return 0.25
+		}
+
+		expectimax := NewExpectimax(&terminalTestGame{newTestGame(root)}, roughHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		if expectimax.rootNode.value != 1.0 {
+			t.Errorf("Root value was %g, expected exactly 1.", expectimax.rootNode.value)
+		}
+		if value := expectimax.rootNode.children[1].value; value != 0.0 {
+			t.Errorf("Drawn line value was %g, expected exactly 0.", value)
+		}
+	})
+}
+
+func TestProofPropagation(t *testing.T) {
+	won := func() *testState { return &testState{value: 1.0, outcome: WinOutcome} }
+	lost := func() *testState { return &testState{value: -1.0, outcome: LossOutcome} }
+	newExpectimax := func(root *testState, options ...ExpectimaxOption) *Expectimax {
+		options = append(options, WithAlternatingPerspective(true))
+		return NewExpectimax(&outcomeTestGame{&terminalTestGame{newTestGame(root)}}, testHeuristic, maxChildLikelihood, 1000, options...)
+	}
+
+	t.Run("test a winning move proves a win and stops the search", func(t *testing.T) {
+		root := playerBranch(0, 0.0, uniformTree(2, 3), won())
+		expectimax := newExpectimax(root, WithProofPropagation(true))
+		exploreAll(expectimax)
+
+		if outcome := expectimax.ProvenOutcome(); outcome != WinOutcome {
+			t.Errorf("ProvenOutcome() was %v, expected a proven win.", outcome)
+		}
+		if nodeCount := expectimax.GetNodeCount(); nodeCount != 2 {
+			t.Errorf("Searched %d nodes, expected only the root's 2 moves once the win was found.", nodeCount)
+		}
+		if bestMove := expectimax.getBestChildMove(); bestMove != 1 {
+			t.Errorf("Best move was %v, expected the winning move 1.", bestMove)
+		}
+	})
+
+	t.Run("test every move losing proves a loss without searching the rest", func(t *testing.T) {
+		// Whatever player 0 does, player 1 can win straight away
+		root := playerBranch(0, 0.0,
+			playerBranch(1, 0.0, uniformTree(2, 3), lost()),
+			playerBranch(1, 0.0, lost(), uniformTree(2, 3)),
+		)
+		expectimax := newExpectimax(root, WithProofPropagation(true))
+		exploreAll(expectimax)
+
+		if outcome := expectimax.ProvenOutcome(); outcome != LossOutcome {
+			t.Errorf("ProvenOutcome() was %v, expected a proven loss.", outcome)
+		}
+		if nodeCount := expectimax.GetNodeCount(); nodeCount != 6 {
+			t.Errorf("Searched %d nodes, expected 6 with the unproven subtrees left alone.", nodeCount)
+		}
+	})
+
+	t.Run("test nothing is proven without the option", func(t *testing.T) {
+		root := playerBranch(0, 0.0, uniformTree(2, 3), won())
+		expectimax := newExpectimax(root)
+		exploreAll(expectimax)
+
+		if outcome := expectimax.ProvenOutcome(); outcome != UnknownOutcome {
+			t.Errorf("ProvenOutcome() was %v, expected nothing proven.", outcome)
+		}
+		if nodeCount := expectimax.GetNodeCount(); nodeCount != 16 {
+			t.Errorf("Searched %d nodes, expected the whole tree of 16.", nodeCount)
+		}
+	})
+}
+
+func TestOutcomeValues(t *testing.T) {
+	// The game scores its narrow losses above its draw
+	newRoot := func() *testState {
+		return branch(0.0,
+			&testState{value: -0.1, outcome: LossOutcome},
+			branch(0.0, &testState{value: -0.5, outcome: DrawOutcome}),
+			&testState{value: -0.2, outcome: LossOutcome},
+		)
+	}
+
+	t.Run("test the engine holds the only non-losing line, a draw", func(t *testing.T) {
+		expectimax := NewExpectimax(&outcomeTestGame{&terminalTestGame{newTestGame(newRoot())}}, testHeuristic, maxChildLikelihood, 1000, WithOutcomeValues(0.0, -1.0))
+		exploreAll(expectimax)
+
+		if move := expectimax.getBestChildMove(); move != 1 {
+			t.Errorf("Best move was %v, expected the drawing move 1.", move)
+		}
+		if value := expectimax.rootNode.value; value != 0.0 {
+			t.Errorf("Root value was %g, expected the draw value 0.", value)
+		}
+	})
+
+	t.Run("test outcomes are ignored without WithOutcomeValues()", func(t *testing.T) {
+		expectimax := NewExpectimax(&outcomeTestGame{&terminalTestGame{newTestGame(newRoot())}}, testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		if move := expectimax.getBestChildMove(); move != 0 {
+			t.Errorf("Best move was %v, expected move 0 with the best TerminalValue.", move)
+		}
+	})
+}
+
+func TestTerminalNodesAreNotExplored(t *testing.T) {
+	t.Run("test finished games are never dispatched to workers", func(t *testing.T) {
+		root := branch(0.0,
+			branch(1.0, leaf(2.0), branch(3.0, leaf(4.0))),
+			leaf(5.0),
+			branch(6.0, leaf(7.0), leaf(8.0)),
+		)
+
+		exploredLeaves := 0
+		exploredNodes := 0
+		onExplore := func(move interface{}, childCount int, value float64) {
+			exploredNodes++
+			if childCount == 0 {
+				exploredLeaves++
+			}
+		}
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000, WithOnExplore(onExplore), WithSearchTimeout(5*time.Second))
+		defer expectimax.Stop()
+		expectimax.Search()
+
+		if exploredLeaves != 0 {
+			t.Errorf("%d finished games were explored.", exploredLeaves)
+		}
+		if exploredNodes != 4 {
+			t.Errorf("%d nodes were explored, expected 4.", exploredNodes)
+		}
+		if expectimax.rootNode.descendentCount != 8 {
+			t.Errorf("Root has %d descendents, expected 8.", expectimax.rootNode.descendentCount)
+		}
+	})
+}
+
+func TestResponsePollInterval(t *testing.T) {
+	timeBestMove := func(options ...ExpectimaxOption) time.Duration {
+		slowHeuristic := func(game Game) float64 {
+			time.Sleep(time.Millisecond)
+			return testHeuristic(game)
+		}
+
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 5)), slowHeuristic, uniformChildLikelihood, 1000, options...)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		start := time.Now()
+		expectimax.GetBestMove()
+		return time.Since(start)
+	}
+
+	t.Run("test WithResponsePollInterval() answers sooner", func(t *testing.T) {
+		defaultElapsed := timeBestMove()
+		fastElapsed := timeBestMove(WithResponsePollInterval(2 * time.Millisecond))
+
+		if defaultElapsed < defaultResponsePollInterval {
+			t.Errorf("GetBestMove() with the default interval answered after %v, expected it to wait at least %v.", defaultElapsed, defaultResponsePollInterval)
+		}
+		if fastElapsed >= defaultElapsed {
+			t.Errorf("GetBestMove() with a 2ms interval took %v, no faster than %v with the default.", fastElapsed, defaultElapsed)
+		}
+	})
+}
+
+func TestGetNextMoveValuesNow(t *testing.T) {
+	t.Run("test GetNextMoveValuesNow() answers before the search is deep enough", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 4)), testHeuristic, uniformChildLikelihood, 1000)
+
+		nextMoveValues, deepEnough := expectimax.GetNextMoveValuesNow()
+		if nextMoveValues == nil || len(*nextMoveValues) != 0 {
+			t.Errorf("GetNextMoveValuesNow() returned %v before exploring, expected an empty map.", nextMoveValues)
+		}
+		if deepEnough {
+			t.Error("GetNextMoveValuesNow() reported the search deep enough before exploring.")
+		}
+
+		exploreAll(expectimax)
+
+		nextMoveValues, deepEnough = expectimax.GetNextMoveValuesNow()
+		if len(*nextMoveValues) != 3 {
+			t.Errorf("GetNextMoveValuesNow() returned %d values, expected 3.", len(*nextMoveValues))
+		}
+		if !deepEnough {
+			t.Error("GetNextMoveValuesNow() reported a fully explored search as not deep enough.")
+		}
+	})
+}
+
+func TestGetNextMoveValuesContext(t *testing.T) {
+	t.Run("test a cancelled context returns the partial values", func(t *testing.T) {
+		// Never explores enough of the endless game to answer by itself
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000000000)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		nextMoveValues, err := expectimax.GetNextMoveValuesContext(ctx)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("GetNextMoveValuesContext() took %v to return after the context was done.", elapsed)
+		}
+		if err != context.DeadlineExceeded {
+			t.Errorf("GetNextMoveValuesContext() returned error %v, expected %v.", err, context.DeadlineExceeded)
+		}
+		if nextMoveValues == nil || len(*nextMoveValues) != 3 {
+			t.Errorf("GetNextMoveValuesContext() returned %v, expected the 3 root moves' values.", nextMoveValues)
+		}
+	})
+
+	t.Run("test GetNextMoveValuesContext() answers once deep enough", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 2)), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		nextMoveValues, err := expectimax.GetNextMoveValuesContext(context.Background())
+		if err != nil || len(*nextMoveValues) != 3 {
+			t.Errorf("GetNextMoveValuesContext() returned %v and %v, expected 3 values and no error.", nextMoveValues, err)
+		}
+	})
+}
+
+func TestConcurrentGetBestMove(t *testing.T) {
+	t.Run("test concurrent GetBestMove() callers each get a move", func(t *testing.T) {
+		slowHeuristic := func(game Game) float64 {
+			time.Sleep(100 * time.Microsecond)
+			return testHeuristic(game)
+		}
+
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 6)), slowHeuristic, uniformChildLikelihood, 10000, WithResponsePollInterval(time.Millisecond))
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		const callerCount = 20
+		bestMoves := make(chan interface{}, callerCount)
+		for i := 0; i < callerCount; i++ {
+			go func() {
+				bestMoves <- expectimax.GetBestMove()
+			}()
+		}
+
+		for i := 0; i < callerCount; i++ {
+			select {
+			case bestMove := <-bestMoves:
+				if move, ok := bestMove.(int); !ok || move < 0 || move > 2 {
+					t.Errorf("GetBestMove() returned %v, expected a move between 0 and 2.", bestMove)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("Only %d of %d GetBestMove() callers received a move.", i, callerCount)
+			}
+		}
+	})
+}
+
+func TestGetMoveValueStats(t *testing.T) {
+	t.Run("test GetMoveValueStats() reports a catastrophic branch", func(t *testing.T) {
+		risky := branch(0.0)
+		for i := 0; i < 9; i++ {
+			risky.children = append(risky.children, leaf(10.0))
+		}
+		risky.children = append(risky.children, leaf(-50.0))
+
+		root := branch(0.0, risky, branch(0.0, leaf(1.0), leaf(3.0)))
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		moveValueStats := expectimax.GetMoveValueStats()
+		if stats := moveValueStats[0]; stats.Mean != 4.0 || stats.Min != -50.0 || stats.Max != 10.0 {
+			t.Errorf("Risky move stats were %+v, expected mean 4, min -50, max 10.", stats)
+		}
+		if stats := moveValueStats[1]; stats.Mean != 2.0 || stats.Min != 1.0 || stats.Max != 3.0 {
+			t.Errorf("Safe move stats were %+v, expected mean 2, min 1, max 3.", stats)
+		}
+	})
+}
+
+func TestLeafValueHistogram(t *testing.T) {
+	root := branch(0.0,
+		branch(1.0, leaf(0.0), branch(2.0, leaf(1.0), leaf(2.0)), leaf(3.0), leaf(4.0), leaf(10.0)),
+		leaf(5.0),
+	)
+	expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+	exploreAll(expectimax)
+
+	t.Run("test leaf values are counted into equal buckets", func(t *testing.T) {
+		if histogram := expectimax.LeafValueHistogram(0, 5); !reflect.DeepEqual(histogram, []int{2, 2, 1, 0, 1}) {
+			t.Errorf("LeafValueHistogram(0, 5) was %v, expected [2 2 1 0 1].", histogram)
+		}
+		if histogram := expectimax.LeafValueHistogram(1, 3); !reflect.DeepEqual(histogram, []int{1, 0, 0}) {
+			t.Errorf("LeafValueHistogram(1, 3) of a single leaf was %v, expected [1 0 0].", histogram)
+		}
+	})
+
+	t.Run("test an unknown move or no buckets gives nil", func(t *testing.T) {
+		if histogram := expectimax.LeafValueHistogram(2, 5); histogram != nil {
+			t.Errorf("LeafValueHistogram(2, 5) was %v, expected nil for a move the root doesn't have.", histogram)
+		}
+		if histogram := expectimax.LeafValueHistogram(0, 0); histogram != nil {
+			t.Errorf("LeafValueHistogram(0, 0) was %v, expected nil.", histogram)
+		}
+	})
+}
+
+func TestChanceNodeProbabilities(t *testing.T) {
+	t.Run("test chance nodes weight outcomes by the game's probabilities", func(t *testing.T) {
+		spawnTwo := &testState{value: 2.0, probability: 0.9}
+		spawnFour := &testState{value: 4.0, probability: 0.1}
+		root := branch(0.0,
+			&testState{chance: true, children: []*testState{spawnTwo, spawnFour}},
+			leaf(2.1),
+		)
+
+		expectimax := NewExpectimax(&chanceTestGame{newTestGame(root)}, testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		spawnNode := expectimax.rootNode.children[0]
+		if spawnNode.childLikelihood[0] != 0.9 || spawnNode.childLikelihood[1] != 0.1 {
+			t.Errorf("Chance node likelihoods were %v, expected 0.9 and 0.1.", spawnNode.childLikelihood)
+		}
+		if math.Abs(spawnNode.value-2.2) > 1e-9 {
+			t.Errorf("Chance node value was %g, expected 2.2.", spawnNode.value)
+		}
+		if math.Abs(expectimax.rootNode.value-2.2) > 1e-9 {
+			t.Errorf("Root value was %g, expected the chance node's 2.2 over the 2.1 leaf.", expectimax.rootNode.value)
+		}
+	})
+}
+
+func TestStop(t *testing.T) {
+	t.Run("test RunExpectimax() returns after Stop()", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000000)
+			returned := make(chan struct{})
+			go func() {
+				expectimax.RunExpectimax()
+				close(returned)
+			}()
+
+			if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) > 10 }) {
+				t.Fatal("Search failed to start.")
+			}
+			expectimax.Stop()
+
+			select {
+			case <-returned:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("RunExpectimax() hadn't returned 5s after Stop() on run %d.", i)
+			}
+		}
+	})
+
+	t.Run("test requests made while stopping return", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000000)
+			go expectimax.RunExpectimax()
+
+			if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) > 10 }) {
+				t.Fatal("Search failed to start.")
+			}
+			expectimax.Stop()
+
+			returned := make(chan struct{})
+			go func() {
+				expectimax.GetValue()
+				expectimax.GetNodeCount()
+				close(returned)
+			}()
+			select {
+			case <-returned:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Requests made after Stop() hadn't returned after 5s on run %d.", i)
+			}
+		}
+	})
+}
+
+func TestPauseResume(t *testing.T) {
+	t.Run("test Pause() stops and Resume() restarts the search", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000000)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) > 100 }) {
+			t.Fatal("Search failed to start.")
+		}
+
+		expectimax.Pause()
+		time.Sleep(20 * time.Millisecond) // Let in-flight explorations finish
+		pausedCount := getNodeCount(expectimax)
+		time.Sleep(50 * time.Millisecond)
+		if nodeCount := getNodeCount(expectimax); nodeCount != pausedCount {
+			t.Errorf("Node count grew from %d to %d while paused.", pausedCount, nodeCount)
+		}
+
+		expectimax.Resume()
+		if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) > pausedCount }) {
+			t.Error("Node count failed to grow after Resume().")
+		}
+	})
+
+	t.Run("test workers wait off the main loop while paused", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000000)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) > 100 }) {
+			t.Fatal("Search failed to start.")
+		}
+
+		expectimax.Pause()
+		if !waitFor(5*time.Second, func() bool { return atomic.LoadInt32(&expectimax.idleWorkerCount) == int32(expectimaxWorkerCount) }) {
+			t.Errorf("%d of %d workers were parked while paused.", atomic.LoadInt32(&expectimax.idleWorkerCount), expectimaxWorkerCount)
+		}
+
+		expectimax.Resume()
+		pausedCount := getNodeCount(expectimax)
+		if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) > pausedCount }) {
+			t.Error("Node count failed to grow after Resume().")
+		}
+	})
+}
+
+func TestRestart(t *testing.T) {
+	anyHeuristic := func(game Game) float64 {
+		if _, ok := game.(*endlessGame); ok {
+			return endlessHeuristic(game)
+		}
+		return testHeuristic(game)
+	}
+
+	t.Run("test Restart() replaces the tree", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), anyHeuristic, uniformChildLikelihood, 1000000)
+		oldRootNode := expectimax.rootNode
+		exploreNode := oldRootNode
+		exploreNode.incrementReference()
+		exploreNode.setWaitingForExploration()
+		exploreNode.Explore(expectimax.settings)
+		expectimax.processExploredNode(exploreNode)
+		exploreNode.decrementReference()
+
+		game := newTestGame(uniformTree(2, 2))
+		expectimax.Restart(game)
+
+		if expectimax.rootNode == oldRootNode || expectimax.rootNode.descendentCount != 0 {
+			t.Errorf("Restart() left a root with %d descendents, expected a fresh root.", expectimax.rootNode.descendentCount)
+		}
+		if len(game.listeners) != 1 {
+			t.Errorf("Restart() registered %d move listeners on the new game, expected 1.", len(game.listeners))
+		}
+	})
+
+	t.Run("test Restart() waits for the old tree's nodes with the workers", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), anyHeuristic, uniformChildLikelihood, 1000000)
+		exploreSteps(expectimax, 1)
+
+		// Hand a child to a worker, as the main loop would, and have it come back
+		expectimax.exploredNodeChannel = make(chan *expectimaxNode, 1)
+		inFlightNode := expectimax.getUnexploredNode()
+		inFlightNode.incrementReference()
+		inFlightNode.setWaitingForExploration()
+		expectimax.inFlight++
+		inFlightNode.Explore(expectimax.settings)
+		expectimax.exploredNodeChannel <- inFlightNode
+
+		expectimax.Restart(newTestGame(uniformTree(2, 2)))
+
+		if expectimax.inFlight != 0 || len(expectimax.exploredNodeChannel) != 0 {
+			t.Fatalf("Restart() left %d nodes of the old tree in flight.", expectimax.inFlight)
+		}
+		if node := expectimax.getUnexploredNode(); node != expectimax.rootNode {
+			t.Errorf("The next node to explore after Restart() wasn't the new root.")
+		}
+	})
+
+	t.Run("test Restart() while searching", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), anyHeuristic, uniformChildLikelihood, 1000000)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) > 100 }) {
+			t.Fatal("Search failed to start.")
+		}
+
+		expectimax.Restart(newTestGame(uniformTree(2, 2)))
+		if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) == 6 }) {
+			t.Errorf("Restarted search has %d nodes, expected the 6 in the new game.", getNodeCount(expectimax))
+		}
+	})
+}
+
+func TestSetHeuristic(t *testing.T) {
+	sharpHeuristic := func(game Game) float64 {
+		return 10.0 * testHeuristic(game)
+	}
+
+	t.Run("test nodes explored after SetHeuristic() use the new heuristic", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 2)), testHeuristic, uniformChildLikelihood, 1000)
+		exploreSteps(expectimax, 1)
+		expectimax.SetHeuristic(sharpHeuristic)
+		exploreAll(expectimax)
+
+		for move, childNode := range expectimax.rootNode.children {
+			state := childNode.GetGame().(testStateGame).state()
+			if childNode.heuristic != state.value {
+				t.Errorf("Move %v explored before the swap has heuristic %g, expected the old %g.", move, childNode.heuristic, state.value)
+			}
+			for grandchildMove, grandchildNode := range childNode.children {
+				expected := 10.0 * grandchildNode.GetGame().(testStateGame).state().value
+				if grandchildNode.heuristic != expected {
+					t.Errorf("Move %v, %v explored after the swap has heuristic %g, expected the new %g.", move, grandchildMove, grandchildNode.heuristic, expected)
+				}
+			}
+		}
+	})
+
+	t.Run("test SetChildLikelihood() while searching", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000000)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) > 100 }) {
+			t.Fatal("Search failed to start.")
+		}
+
+		var calls int64
+		expectimax.SetChildLikelihood(func(getGame func() Game, getChildValue func(interface{}) float64, childLikelihood *extensions.ValueMap) {
+			atomic.AddInt64(&calls, 1)
+			uniformChildLikelihood(getGame, getChildValue, childLikelihood)
+		})
+		if !waitFor(5*time.Second, func() bool { return atomic.LoadInt64(&calls) > 0 }) {
+			t.Errorf("The search didn't use the new child likelihood function.")
+		}
+	})
+}
+
+func TestRecomputeValues(t *testing.T) {
+	t.Run("test RecomputeValues() backs up the new heuristic", func(t *testing.T) {
+		sharpHeuristic := func(game Game) float64 {
+			return 2.0*testHeuristic(game) + 1.0
+		}
+		expected := NewExpectimax(newTestGame(uniformTree(2, 3)), sharpHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expected)
+
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 3)), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+		expectimax.SetHeuristic(sharpHeuristic)
+		if value := expectimax.GetValue(); value == expected.GetValue() {
+			t.Fatalf("Value %g already reflects the new heuristic before RecomputeValues().", value)
+		}
+
+		expectimax.RecomputeValues()
+		if value := expectimax.GetValue(); math.Abs(value-expected.GetValue()) > 1e-9 {
+			t.Errorf("Value after RecomputeValues() is %g, expected %g as if searched with the new heuristic.", value, expected.GetValue())
+		}
+	})
+
+	t.Run("test RecomputeValues() while searching", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000000)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) > 100 }) {
+			t.Fatal("Search failed to start.")
+		}
+
+		expectimax.SetHeuristic(ZeroHeuristic)
+		expectimax.RecomputeValues()
+		if value := expectimax.GetValue(); value != 0.0 {
+			t.Errorf("Value after recomputing with ZeroHeuristic is %g, expected 0.", value)
+		}
+	})
+}
+
+func TestGetBestMoveTimed(t *testing.T) {
+	timedSearch := func(remaining time.Duration) (time.Duration, int) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000000)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		start := time.Now()
+		expectimax.GetBestMoveTimed(remaining, 10)
+		return time.Since(start), getNodeCount(expectimax)
+	}
+
+	t.Run("test GetBestMoveTimed() budgets the remaining time", func(t *testing.T) {
+		shortElapsed, shortNodeCount := timedSearch(10 * time.Millisecond)
+		longElapsed, longNodeCount := timedSearch(5 * time.Second)
+
+		// The bounds allow for the main loop competing with the workers for a CPU.
+		if shortElapsed > 200*time.Millisecond {
+			t.Errorf("GetBestMoveTimed() with 10ms for 10 moves took %v.", shortElapsed)
+		}
+		if longElapsed < 400*time.Millisecond || longElapsed > 2*time.Second {
+			t.Errorf("GetBestMoveTimed() with 5s for 10 moves took %v, expected about 450ms.", longElapsed)
+		}
+		if longNodeCount <= shortNodeCount {
+			t.Errorf("Longer search explored %d nodes, no more than the %d of the shorter.", longNodeCount, shortNodeCount)
+		}
+	})
+}
+
+func TestCycleDetection(t *testing.T) {
+	t.Run("test repeated positions are valued as draws", func(t *testing.T) {
+		root := &testState{value: 5.0, hash: 1}
+		repeat := &testState{value: 5.0, hash: 2, children: []*testState{root}}
+		root.children = []*testState{repeat, {value: -1.0, hash: 3}}
+
+		expectimax := NewExpectimax(&hashedTestGame{newTestGame(root)}, testHeuristic, maxChildLikelihood, 1000, WithDrawValue(0.25))
+		exploreAll(expectimax)
+
+		if expectimax.rootNode.descendentCount != 3 {
+			t.Errorf("Root has %d descendents, expected the cycle to be cut at 3.", expectimax.rootNode.descendentCount)
+		}
+
+		cycleNode := expectimax.rootNode.children[0].children[0]
+		if cycleNode.value != 0.25 || cycleNode.explorationStatus != Archived {
+			t.Errorf("Repeated position has value %g and status %v, expected an archived draw worth 0.25.", cycleNode.value, cycleNode.explorationStatus)
+		}
+		if expectimax.rootNode.value != 0.25 {
+			t.Errorf("Root value was %g, expected the drawing line's 0.25.", expectimax.rootNode.value)
+		}
+	})
+
+	t.Run("test repeated positions are found with only Equal", func(t *testing.T) {
+		root := &testState{value: 5.0}
+		repeat := &testState{value: 5.0, children: []*testState{root}}
+		root.children = []*testState{repeat, {value: -1.0}}
+
+		expectimax := NewExpectimax(&unhashedEqualTestGame{newTestGame(root)}, testHeuristic, maxChildLikelihood, 1000,
+			WithDrawValue(0.25), WithEqualCycleDetection(true))
+		exploreAll(expectimax)
+
+		if expectimax.rootNode.descendentCount != 3 {
+			t.Errorf("Root has %d descendents, expected the cycle to be cut at 3.", expectimax.rootNode.descendentCount)
+		}
+
+		cycleNode := expectimax.rootNode.children[0].children[0]
+		if cycleNode.value != 0.25 || cycleNode.explorationStatus != Archived {
+			t.Errorf("Repeated position has value %g and status %v, expected an archived draw worth 0.25.", cycleNode.value, cycleNode.explorationStatus)
+		}
+		if expectimax.rootNode.value != 0.25 {
+			t.Errorf("Root value was %g, expected the drawing line's 0.25.", expectimax.rootNode.value)
+		}
+	})
+
+	t.Run("test Equal isn't used for cycles unless enabled", func(t *testing.T) {
+		root := &testState{value: 5.0}
+		repeat := &testState{value: 5.0, children: []*testState{root}}
+		root.children = []*testState{repeat, {value: -1.0}}
+
+		expectimax := NewExpectimax(&unhashedEqualTestGame{newTestGame(root)}, testHeuristic, maxChildLikelihood, 1000)
+		exploreSteps(expectimax, 3)
+
+		if cycleNode := expectimax.rootNode.children[0].children[0]; len(cycleNode.children) == 0 {
+			t.Errorf("Repeated position wasn't expanded without WithEqualCycleDetection().")
+		}
+	})
+}
+
+func TestWorkerStats(t *testing.T) {
+	t.Run("test worker stats are off by default", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 2)), testHeuristic, uniformChildLikelihood, 1000)
+		if expectimax.WorkerStats() != nil {
+			t.Errorf("Expected no worker stats unless enabled.")
+		}
+	})
+
+	t.Run("test worker stats cover all explored nodes", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 3)), testHeuristic, uniformChildLikelihood, 1000, WithWorkerStats(true), WithSearchTimeout(5*time.Second))
+		defer expectimax.Stop()
+		expectimax.Search()
+
+		countExplored := func(workerStats []WorkerStat) int {
+			nodesExplored := 0
+			for _, stat := range workerStats {
+				nodesExplored += stat.NodesExplored
+			}
+			return nodesExplored
+		}
+
+		// Workers record their stats just before handing the node back.
+		waitFor(time.Second, func() bool {
+			return int64(countExplored(expectimax.WorkerStats())) == expectimax.stats.getExploredNodes()
+		})
+
+		workerStats := expectimax.WorkerStats()
+		if len(workerStats) != expectimaxWorkerCount {
+			t.Fatalf("Got stats for %d workers, expected %d.", len(workerStats), expectimaxWorkerCount)
+		}
+
+		for i, stat := range workerStats {
+			if stat.ID != i {
+				t.Errorf("Worker %d reported ID %d.", i, stat.ID)
+			}
+
+			histogramTotal := 0
+			for _, count := range stat.Histogram {
+				histogramTotal += count
+			}
+			if histogramTotal != stat.NodesExplored {
+				t.Errorf("Worker %d histogram holds %d calls, expected %d.", i, histogramTotal, stat.NodesExplored)
+			}
+		}
+
+		if nodesExplored := countExplored(workerStats); nodesExplored == 0 || int64(nodesExplored) != expectimax.stats.getExploredNodes() {
+			t.Errorf("Workers explored %d nodes, expected %d.", nodesExplored, expectimax.stats.getExploredNodes())
+		}
+	})
+}
+
+func TestMoveHeuristic(t *testing.T) {
+	t.Run("test the move heuristic receives the move that led to each state", func(t *testing.T) {
+		root := branch(0.0, leaf(1.0), leaf(2.0), leaf(3.0))
+
+		moveHeuristic := func(game Game, lastMove interface{}) float64 {
+			if game.(testStateGame).state() != root.children[lastMove.(int)] {
+				t.Errorf("Move heuristic was given move %v for the wrong state.", lastMove)
+			}
+			return 10.0 * float64(lastMove.(int))
+		}
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000, WithMoveHeuristic(moveHeuristic))
+		exploreAll(expectimax)
+
+		for move, childNode := range expectimax.rootNode.children {
+			if childNode.heuristic != 10.0*float64(move.(int)) {
+				t.Errorf("Move %v has heuristic %g, expected %g.", move, childNode.heuristic, 10.0*float64(move.(int)))
+			}
+		}
+	})
+}
+
+// referencesBalanced reports whether every node below root is referenced exactly
+// once by each node in the tree that has it as its most likely unexplored
+// descendent, and root by nothing.
+func referencesBalanced(root *expectimaxNode) bool {
+	expected := map[*expectimaxNode]int{root: 0}
+	var visit func(node *expectimaxNode)
+	visit = func(node *expectimaxNode) {
+		if node.mostLikelyUnexploredDescendent != nil && node.mostLikelyUnexploredDescendent != node {
+			expected[node.mostLikelyUnexploredDescendent]++
+		}
+		for _, childNode := range node.children {
+			if _, ok := expected[childNode]; !ok {
+				expected[childNode] = 0
+			}
+			visit(childNode)
+		}
+	}
+	visit(root)
+
+	for node, referenceCount := range expected {
+		if int(atomic.LoadInt32(&node.referenceCount)) != referenceCount {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAdvanceToState(t *testing.T) {
+	moves := []interface{}{0, 1, 0, 1, 0}
+
+	t.Run("test advancing keeps the searched subtree", func(t *testing.T) {
+		root := uniformTree(2, 6)
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		expectedNode, expectedState := expectimax.rootNode, root
+		for _, move := range moves {
+			expectedNode, expectedState = expectedNode.children[move], expectedState.children[move.(int)]
+		}
+
+		if err := expectimax.AdvanceToState(moves); err != nil {
+			t.Fatalf("AdvanceToState returned %v.", err)
+		}
+
+		if expectimax.rootNode != expectedNode {
+			t.Fatalf("Root wasn't moved to the existing node for the position.")
+		}
+		if expectimax.rootNode.parent != nil || expectimax.rootNode.game.(testStateGame).state() != expectedState {
+			t.Errorf("Root wasn't detached with the game for the position.")
+		}
+		if expectimax.rootNode.descendentCount != 2 {
+			t.Errorf("Root has %d descendents, expected 2.", expectimax.rootNode.descendentCount)
+		}
+		if !waitFor(time.Second, func() bool { return referencesBalanced(expectimax.rootNode) }) {
+			t.Errorf("Reference counts weren't balanced after advancing.")
+		}
+	})
+
+	t.Run("test advancing explores unexplored positions on the way", func(t *testing.T) {
+		root := uniformTree(2, 6)
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+
+		if err := expectimax.AdvanceToState(moves); err != nil {
+			t.Fatalf("AdvanceToState returned %v.", err)
+		}
+
+		if expectimax.rootNode.game.(testStateGame).state() != root.children[0].children[1].children[0].children[1].children[0] {
+			t.Errorf("Root wasn't moved to the position after the moves.")
+		}
+		if !waitFor(time.Second, func() bool { return referencesBalanced(expectimax.rootNode) }) {
+			t.Errorf("Reference counts weren't balanced after advancing.")
+		}
+	})
+
+	t.Run("test an impossible move leaves the root in place", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 6)), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+		rootNode := expectimax.rootNode
+
+		if err := expectimax.AdvanceToState([]interface{}{0, 1, 2}); err == nil {
+			t.Errorf("Expected an error advancing through an impossible move.")
+		}
+		if expectimax.rootNode != rootNode {
+			t.Errorf("Root was moved despite the error.")
+		}
+	})
+}
+
+func TestKeepSubtree(t *testing.T) {
+	t.Run("test a kept sibling subtree isn't freed on a descent", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 3)), testHeuristic, uniformChildLikelihood, 1000,
+			WithKeepSubtree(func(move interface{}) bool { return move == 1 }))
+		exploreAll(expectimax)
+
+		keptNode, freedNode := expectimax.rootNode.children[1], expectimax.rootNode.children[2]
+		// A freed node is marked, then emptied once it's back in the pool
+		freed := func(node *expectimaxNode) bool { return node.isMarkedForDeletion() || len(node.children) == 0 }
+		if err := expectimax.AdvanceToState([]interface{}{0}); err != nil {
+			t.Fatalf("AdvanceToState() failed: %v", err)
+		}
+		if !waitFor(time.Second, func() bool { return expectimax.cleaner.getWorkerCount() == 0 }) {
+			t.Fatalf("The old tree wasn't cleaned up.")
+		}
+
+		if freed(keptNode) || freed(keptNode.children[0]) {
+			t.Errorf("The kept subtree of move 1 was freed.")
+		}
+		if !freed(freedNode) {
+			t.Errorf("The subtree of move 2 wasn't freed.")
+		}
+		if treeNode := expectimax.ExportKeptSubtree(1, -1); treeNode == nil || len(treeNode.Children) != 3 {
+			t.Errorf("ExportKeptSubtree(1) was %+v, expected the kept move's explored subtree.", treeNode)
+		}
+		if treeNode := expectimax.ExportKeptSubtree(2, -1); treeNode != nil {
+			t.Errorf("ExportKeptSubtree(2) was %+v, expected nil for a move that wasn't kept.", treeNode)
+		}
+
+		// The next descent frees what the last one kept
+		if err := expectimax.AdvanceToState([]interface{}{0}); err != nil {
+			t.Fatalf("AdvanceToState() failed: %v", err)
+		}
+		if !waitFor(time.Second, func() bool { return freed(keptNode) }) {
+			t.Errorf("The kept subtree wasn't freed at the next descent.")
+		}
+	})
+}
+
+func TestMoveHistory(t *testing.T) {
+	t.Run("test each move played is recorded with the values before it", func(t *testing.T) {
+		root := branch(0.0,
+			branch(0.0, branch(0.0, leaf(1.0), leaf(2.0)), branch(0.0, leaf(-1.0), leaf(4.0))),
+			branch(0.0, branch(0.0, leaf(3.0), leaf(0.5)), branch(0.0, leaf(2.5), leaf(-2.0))),
+		)
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000, WithMoveHistoryRecording(true))
+		exploreAll(expectimax)
+
+		var expected []MoveRecord
+		for _, move := range []interface{}{0, 1, 0} {
+			nextMoveValues, _ := expectimax.GetNextMoveValuesNow()
+			expected = append(expected, MoveRecord{MoveValues: *nextMoveValues, BestMove: expectimax.getBestChildMove(), Move: move, Value: expectimax.GetValue()})
+			if err := expectimax.AdvanceToState([]interface{}{move}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		history := expectimax.MoveHistory()
+		if len(history) != 3 {
+			t.Fatalf("History had %d records after three moves, expected 3.", len(history))
+		}
+		for i, record := range history {
+			if path := record.Position.(*testGame).path; len(path) != i {
+				t.Errorf("Record %d was of the position after %v, expected the position after %d moves.", i, path, i)
+			}
+			record.Position = nil
+			if !reflect.DeepEqual(record, expected[i]) {
+				t.Errorf("Record %d was %+v, expected %+v.", i, record, expected[i])
+			}
+		}
+	})
+
+	t.Run("test Advance() is an error when the position to record has no game", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 2)), testHeuristic, maxChildLikelihood, 1000, WithMoveHistoryRecording(true))
+		exploreAll(expectimax)
+		rootNode := expectimax.rootNode
+		rootNode.game = nil
+
+		if _, err := expectimax.Advance(0); err == nil {
+			t.Errorf("Advance() returned no error recording a position with no game.")
+		}
+		if expectimax.rootNode != rootNode || len(expectimax.MoveHistory()) != 0 {
+			t.Errorf("Advance() moved the root or recorded the move after failing.")
+		}
+	})
+}
+
+func TestShouldResign(t *testing.T) {
+	// Plays moves of the endless game, searching a little before each, and
+	// returns ShouldResign after each move
+	playMoves := func(expectimax *Expectimax, count int) []bool {
+		var resigned []bool
+		for i := 0; i < count; i++ {
+			exploreSteps(expectimax, 20)
+			if err := expectimax.AdvanceToState([]interface{}{0}); err != nil {
+				t.Fatal(err)
+			}
+			resigned = append(resigned, expectimax.ShouldResign())
+		}
+		return resigned
+	}
+	losingHeuristic := func(game Game) float64 {
+		return -1.0
+	}
+
+	t.Run("test a persistently losing value resigns after the configured moves", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), losingHeuristic, uniformChildLikelihood, 100000, WithResignThreshold(-0.5, 3))
+		if resigned := playMoves(expectimax, 4); !reflect.DeepEqual(resigned, []bool{false, false, true, true}) {
+			t.Errorf("ShouldResign() after each move was %v, expected true from the third move.", resigned)
+		}
+
+		expectimax.Restart(newEndlessGame(3))
+		if expectimax.ShouldResign() {
+			t.Errorf("ShouldResign() was still true after Restart().")
+		}
+	})
+
+	t.Run("test a value above the threshold never resigns", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), losingHeuristic, uniformChildLikelihood, 100000, WithResignThreshold(-2.0, 1))
+		if resigned := playMoves(expectimax, 3); !reflect.DeepEqual(resigned, []bool{false, false, false}) {
+			t.Errorf("ShouldResign() after each move was %v, expected false throughout.", resigned)
+		}
+	})
+
+	t.Run("test ShouldResign() is false without a threshold", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), losingHeuristic, uniformChildLikelihood, 100000)
+		if resigned := playMoves(expectimax, 3); !reflect.DeepEqual(resigned, []bool{false, false, false}) {
+			t.Errorf("ShouldResign() after each move was %v without a threshold, expected false throughout.", resigned)
+		}
+	})
+}
+
+func TestAdvance(t *testing.T) {
+	root := branch(0.0,
+		branch(1.0, leaf(2.0), leaf(-3.0)),
+		branch(-1.0, leaf(1.0), leaf(1.5)),
+	)
+
+	t.Run("test Advance() returns the child's value from before the move", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+		childValue := expectimax.rootNode.children[1].value
+
+		value, err := expectimax.Advance(1)
+		if err != nil {
+			t.Fatalf("Advance() failed: %v", err)
+		}
+		if value != childValue || value != 1.25 {
+			t.Errorf("Advance() returned %g, expected the child's value %g.", value, childValue)
+		}
+	})
+
+	t.Run("test Advance() explores an unexplored new root", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		exploreSteps(expectimax, 1)
+
+		value, err := expectimax.Advance(0)
+		if err != nil {
+			t.Fatalf("Advance() failed: %v", err)
+		}
+		if value != -0.5 || len(expectimax.rootNode.children) != 2 {
+			t.Errorf("Advance() returned %g with %d children, expected -0.5 backed up from 2 children.", value, len(expectimax.rootNode.children))
+		}
+	})
+
+	t.Run("test Advance() fails for an illegal move", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+		rootNode := expectimax.rootNode
+
+		if _, err := expectimax.Advance(5); err == nil {
+			t.Errorf("Advance() succeeded for an illegal move.")
+		}
+		if expectimax.rootNode != rootNode {
+			t.Errorf("Advance() moved the root for an illegal move.")
+		}
+	})
+}
+
+func TestNotifyMove(t *testing.T) {
+	root := branch(0.0,
+		branch(1.0, leaf(2.0), leaf(-3.0)),
+		branch(-1.0, leaf(1.0), leaf(1.5)),
+	)
+	rootState := func(expectimax *Expectimax) *testState {
+		game, err := expectimax.GameAt(nil)
+		if err != nil {
+			return nil
+		}
+		return game.(testStateGame).state()
+	}
+
+	t.Run("test NotifyMove() re-roots a running search like a listener move", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, expectimax.IsFullyExplored) {
+			t.Fatalf("The game was never fully explored.")
+		}
+		childValue := (*expectimax.GetNextMoveValues())[1]
+
+		expectimax.NotifyMove(1)
+		if !waitFor(5*time.Second, func() bool { return rootState(expectimax) == root.children[1] }) {
+			t.Fatalf("NotifyMove() didn't move the root to the child.")
+		}
+		if value := expectimax.GetValue(); value != childValue {
+			t.Errorf("Root value was %g, expected the child's searched value %g.", value, childValue)
+		}
+	})
+
+	t.Run("test NotifyMove() re-roots at once when not running", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		expectimax.NotifyMove(0)
+		if state := rootState(expectimax); state != root.children[0] {
+			t.Errorf("NotifyMove() left the root at %v, expected the state after move 0.", state)
+		}
+	})
+
+	t.Run("test an illegal move is an error when not running", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		if err := expectimax.NotifyMove(5); err == nil {
+			t.Errorf("NotifyMove() returned no error for an illegal move.")
+		}
+		if state := rootState(expectimax); state != root {
+			t.Errorf("NotifyMove() moved the root for an illegal move.")
+		}
+	})
+
+	t.Run("test an illegal move is reported and dropped when running", func(t *testing.T) {
+		errs := make(chan error, 10)
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000, WithErrorCallback(func(err error) { errs <- err }))
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, expectimax.IsFullyExplored) {
+			t.Fatalf("The game was never fully explored.")
+		}
+		expectimax.NotifyMove(5)
+		select {
+		case <-errs:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The illegal move was never reported.")
+		}
+
+		expectimax.NotifyMove(1)
+		if !waitFor(5*time.Second, func() bool { return rootState(expectimax) == root.children[1] }) {
+			t.Errorf("A legal move after the dropped one didn't move the root.")
+		}
+	})
+}
+
+func TestExplorationStatusCounts(t *testing.T) {
+	t.Run("test the counts cover every node", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000000)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+		waitFor(time.Second, func() bool { return getNodeCount(expectimax) > 100 })
+
+		// Let the explorations in flight finish so the tree holds still
+		expectimax.Pause()
+		var counts map[string]int
+		waitFor(time.Second, func() bool {
+			counts = expectimax.ExplorationStatusCounts()
+			return counts["WaitingForExploration"]+counts["Exploring"]+counts["Explored"] == 0
+		})
+		descendentCount := getNodeCount(expectimax)
+
+		total := 0
+		for _, count := range counts {
+			total += count
+		}
+		if total != descendentCount+1 {
+			t.Errorf("Status counts %v sum to %d, expected %d.", counts, total, descendentCount+1)
+		}
+		if counts["Archived"] == 0 || counts["Unexplored"] == 0 {
+			t.Errorf("Status counts %v, expected both Archived and Unexplored nodes mid-search.", counts)
+		}
+	})
+}
+
+func TestMinResponseThreshold(t *testing.T) {
+	respond := func(options ...ExpectimaxOption) (time.Duration, int) {
+		options = append(options, WithResponsePollInterval(time.Millisecond))
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 100000, options...)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		start := time.Now()
+		expectimax.GetBestMove()
+		elapsed := time.Since(start)
+		expectimax.Pause()
+		return elapsed, getNodeCount(expectimax)
+	}
+
+	t.Run("test a higher fraction waits for more of the tree", func(t *testing.T) {
+		lowElapsed, lowNodeCount := respond(WithMinResponseFraction(0.001))
+		highElapsed, highNodeCount := respond(WithMinResponseFraction(0.3))
+
+		if highNodeCount < 30000 {
+			t.Errorf("GetBestMove() answered with %d nodes, expected at least 30%% of 100000.", highNodeCount)
+		}
+		if highNodeCount <= lowNodeCount || highElapsed <= lowElapsed {
+			t.Errorf("A 30%% threshold answered after %v with %d nodes, expected longer than 0.1%%'s %v with %d.", highElapsed, highNodeCount, lowElapsed, lowNodeCount)
+		}
+	})
+
+	t.Run("test an absolute threshold replaces the fraction", func(t *testing.T) {
+		_, nodeCount := respond(WithMinResponseFraction(0.5), WithMinResponseNodes(500))
+		if nodeCount < 500 || nodeCount >= 50000 {
+			t.Errorf("GetBestMove() answered with %d nodes, expected at least 500 and well short of half the budget.", nodeCount)
+		}
+	})
+
+	t.Run("test invalid thresholds are clamped", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000, WithMinResponseFraction(2.0))
+		if expectimax.minResponseFraction != 1.0 {
+			t.Errorf("Fraction 2 was stored as %g, expected it clamped to 1.", expectimax.minResponseFraction)
+		}
+		expectimax = NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000, WithMinResponseNodes(-5))
+		if expectimax.minResponseNodes != 0 {
+			t.Errorf("Node count -5 was stored as %d, expected it clamped to 0.", expectimax.minResponseNodes)
+		}
+	})
+}
+
+func TestTinyMaxNodeCount(t *testing.T) {
+	t.Run("test a budget of one node still gives a legal move", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 4)), testHeuristic, uniformChildLikelihood, 1)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		bestMove := expectimax.GetBestMove()
+		if move, ok := bestMove.(int); !ok || move < 0 || move > 2 {
+			t.Errorf("GetBestMove() returned %v, expected a move between 0 and 2.", bestMove)
+		}
+	})
+
+	t.Run("test a non-positive budget uses the default", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 4)), testHeuristic, uniformChildLikelihood, 0)
+		if expectimax.maxNodeCount != defaultMaxNodeCount {
+			t.Errorf("Max node count 0 was stored as %d, expected the default %d.", expectimax.maxNodeCount, defaultMaxNodeCount)
+		}
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Run("test advancing a snapshot leaves the original alone", func(t *testing.T) {
+		root := uniformTree(3, 4)
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000, WithSearchTimeout(5*time.Second))
+		defer expectimax.Stop()
+		expectimax.Search()
+
+		before := *expectimax.GetNextMoveValues()
+		snapshot := expectimax.Snapshot()
+		if snapshot.rootNode == expectimax.rootNode || snapshot.rootNode.descendentCount != expectimax.rootNode.descendentCount {
+			t.Fatalf("Snapshot didn't copy the %d node tree.", expectimax.rootNode.descendentCount)
+		}
+
+		if err := snapshot.AdvanceToState([]interface{}{1}); err != nil {
+			t.Fatalf("AdvanceToState returned %v.", err)
+		}
+		if snapshot.rootNode.game.(testStateGame).state() != root.children[1] {
+			t.Errorf("Snapshot root wasn't advanced to the position after move 1.")
+		}
+
+		after := *expectimax.GetNextMoveValues()
+		if len(after) != len(before) {
+			t.Fatalf("Original has %d move values after the snapshot advanced, expected %d.", len(after), len(before))
+		}
+		for move, value := range before {
+			if after[move] != value {
+				t.Errorf("Original value for move %v changed from %g to %g.", move, value, after[move])
+			}
+		}
+
+		if !waitFor(time.Second, func() bool { return referencesBalanced(snapshot.rootNode) }) {
+			t.Errorf("Snapshot reference counts weren't balanced after advancing.")
+		}
+		expectimax.Pause()
+		if !waitFor(time.Second, func() bool { return referencesBalanced(expectimax.rootNode) }) {
+			t.Errorf("Original reference counts weren't balanced after the snapshot advanced.")
+		}
+	})
+}
+
+func TestOnBestMoveChange(t *testing.T) {
+	t.Run("test deeper search reversing the best move fires an event", func(t *testing.T) {
+		// Move 0 looks better until its replies are seen
+		root := branch(0.0,
+			branch(1.0, leaf(-5.0), leaf(-4.0)),
+			branch(0.0, leaf(2.0), leaf(3.0)),
+		)
+
+		var changes []BestMoveChange
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000, WithOnBestMoveChange(func(change BestMoveChange) {
+			changes = append(changes, change)
+		}))
+		exploreAll(expectimax)
+
+		if len(changes) != 2 {
+			t.Fatalf("Got best move changes %v, expected two.", changes)
+		}
+		if changes[0].From != nil || changes[0].To != 0 || changes[0].ToValue != 1.0 || changes[0].NodeCount != 2 {
+			t.Errorf("First change was %+v, expected move 0 worth 1 once the root was explored.", changes[0])
+		}
+		if changes[1].From != 0 || changes[1].To != 1 || changes[1].FromValue != -4.0 || changes[1].ToValue != 0.0 {
+			t.Errorf("Second change was %+v, expected move 0 worth -4 to give way to move 1 worth 0.", changes[1])
+		}
+	})
+}
+
+func TestNilSearchFunctions(t *testing.T) {
+	t.Run("test nil heuristic and likelihood fall back to the defaults", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 3)), nil, nil, 1000, WithSearchTimeout(5*time.Second))
+		defer expectimax.Stop()
+
+		result := expectimax.Search()
+		if move, ok := result.BestMove.(int); !ok || move < 0 || move > 2 {
+			t.Errorf("Search() best move was %v, expected a move between 0 and 2.", result.BestMove)
+		}
+		if result.NodeCount != 39 || result.Value != 0.0 {
+			t.Errorf("Search() explored %d nodes worth %g, expected all 39 worth ZeroHeuristic's 0.", result.NodeCount, result.Value)
+		}
+	})
+
+	t.Run("test FirstChildLikelihood picks the first possible move", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 1)), ZeroHeuristic, FirstChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		for move, likelihood := range expectimax.rootNode.childLikelihood {
+			expected := 0.0
+			if move == 0 {
+				expected = 1.0
+			}
+			if likelihood != expected {
+				t.Errorf("Move %v has likelihood %g, expected %g.", move, likelihood, expected)
+			}
+		}
+	})
+}
+
+func TestGetRootMoveLikelihoods(t *testing.T) {
+	root := branch(0.0, leaf(1.0), leaf(3.0), leaf(2.0))
+
+	t.Run("test the likelihoods come from the child likelihood function", func(t *testing.T) {
+		for _, test := range []struct {
+			name                     string
+			calculateChildLikelihood ExpectimaxChildLikelihoodFunc
+			expected                 map[interface{}]float64
+		}{
+			{"uniform", uniformChildLikelihood, map[interface{}]float64{0: 1.0 / 3.0, 1: 1.0 / 3.0, 2: 1.0 / 3.0}},
+			{"max", maxChildLikelihood, map[interface{}]float64{0: 0.0, 1: 1.0, 2: 0.0}},
+		} {
+			expectimax := NewExpectimax(newTestGame(root), testHeuristic, test.calculateChildLikelihood, 1000)
+			exploreAll(expectimax)
+
+			likelihoods := expectimax.GetRootMoveLikelihoods()
+			var total float64
+			for move, expected := range test.expected {
+				if math.Abs(likelihoods[move]-expected) > 1e-9 {
+					t.Errorf("With %s likelihoods, move %v had likelihood %g, expected %g.", test.name, move, likelihoods[move], expected)
+				}
+				total += likelihoods[move]
+			}
+			if math.Abs(total-1.0) > 1e-9 {
+				t.Errorf("With %s likelihoods, they summed to %g, expected 1.", test.name, total)
+			}
+		}
+	})
+}
+
+func TestGetRootChildHeuristics(t *testing.T) {
+	t.Run("test the heuristics are the children's own estimates", func(t *testing.T) {
+		root := branch(0.0, branch(1.0, leaf(4.0), leaf(-2.0)), branch(-1.0, leaf(3.0), leaf(5.0)))
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		heuristics := expectimax.GetRootChildHeuristics()
+		nextMoveValues, _ := expectimax.GetNextMoveValuesNow()
+		for move, expected := range map[interface{}]float64{0: 1.0, 1: -1.0} {
+			if heuristics[move] != expected {
+				t.Errorf("Heuristic of move %v was %g, expected %g.", move, heuristics[move], expected)
+			}
+			if (*nextMoveValues)[move] == heuristics[move] {
+				t.Errorf("Value of move %v was still its heuristic %g after searching beneath it.", move, heuristics[move])
+			}
+		}
+	})
+}
+
+func TestGetNextMoveValueDeltas(t *testing.T) {
+	t.Run("test the best move reports 0 and the others how far behind they are", func(t *testing.T) {
+		root := branch(0.0, leaf(1.0), leaf(3.0), leaf(2.5))
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		expected := map[interface{}]float64{0: -2.0, 1: 0.0, 2: -0.5}
+		if deltas := expectimax.GetNextMoveValueDeltas(); !reflect.DeepEqual(deltas, expected) {
+			t.Errorf("Deltas were %v, expected %v.", deltas, expected)
+		}
+	})
+
+	t.Run("test deltas are from the point of view of the player to move", func(t *testing.T) {
+		root := playerBranch(1, 0.0, leaf(1.0), leaf(3.0))
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000, WithAlternatingPerspective(true))
+		exploreAll(expectimax)
+
+		expected := map[interface{}]float64{0: 0.0, 1: -2.0}
+		if deltas := expectimax.GetNextMoveValueDeltas(); !reflect.DeepEqual(deltas, expected) {
+			t.Errorf("Deltas were %v, expected %v for the opponent's lower values.", deltas, expected)
+		}
+	})
+}
+
+func TestGetRootChildDepths(t *testing.T) {
+	t.Run("test a deeply searched move reports a greater depth than a shallow one", func(t *testing.T) {
+		root := branch(0.0, uniformTree(2, 4), branch(0.0, leaf(1.0)))
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		depths := expectimax.GetRootChildDepths()
+		if depths[0] != 4.0 || depths[1] != 1.0 {
+			t.Errorf("Depths were %v, expected 4 beneath move 0 and 1 beneath move 1.", depths)
+		}
+	})
+}
+
+func TestGetRootExploreProbabilities(t *testing.T) {
+	t.Run("test the probabilities sum to 1 after the root is explored", func(t *testing.T) {
+		root := branch(0.0, leaf(1.0), leaf(3.0), leaf(2.0))
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreSteps(expectimax, 1)
+
+		exploreProbabilities := expectimax.GetRootExploreProbabilities()
+		if len(exploreProbabilities) != 3 {
+			t.Fatalf("Got %d probabilities, expected one for each of the 3 moves.", len(exploreProbabilities))
+		}
+
+		var total float64
+		for _, exploreProbability := range exploreProbabilities {
+			total += exploreProbability
+		}
+		if math.Abs(total-1.0) > 1e-9 {
+			t.Fatalf("Probabilities summed to %g, expected 1.", total)
+		}
+
+		// The best move takes its likelihood of 1 less the spread, the others only the spread
+		if exploreProbabilities[1] <= exploreProbabilities[0] || exploreProbabilities[0] != exploreProbabilities[2] {
+			t.Fatalf("Probabilities were %v, expected move 1 to be favoured with the spread shared evenly.", exploreProbabilities)
+		}
+	})
+}
+
+func TestPrior(t *testing.T) {
+	root := branch(0.0, uniformTree(2, 1), uniformTree(2, 1), uniformTree(2, 1))
+	favourMove2 := func(game Game) map[interface{}]float64 {
+		return map[interface{}]float64{0: 0.01, 1: 0.01, 2: 10.0}
+	}
+
+	t.Run("test a strongly favoured move is explored first", func(t *testing.T) {
+		for run := 0; run < 10; run++ {
+			expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000, WithPrior(favourMove2, 0.5))
+			exploreSteps(expectimax, 1)
+
+			if next := expectimax.rootNode.mostLikelyUnexploredDescendent; next != expectimax.rootNode.children[2] {
+				t.Fatalf("The next node to explore was after move %v, expected the favoured move 2.", next.lastMove)
+			}
+		}
+	})
+
+	t.Run("test the prior leaves values alone", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000, WithPrior(favourMove2, 1.0))
+		exploreAll(expectimax)
+
+		for move, likelihood := range expectimax.rootNode.childLikelihood {
+			if likelihood != 1.0/3.0 {
+				t.Fatalf("Likelihood of move %v was %g, expected the uniform 1/3.", move, likelihood)
+			}
+		}
+	})
+}
+
+func TestUncertaintyWeighting(t *testing.T) {
+	// Beneath move 0 every line is worth about the same; beneath move 2 the
+	// lines differ widely
+	heuristic := func(game Game) float64 {
+		path := game.(*endlessGame).path
+		if len(path) == 0 {
+			return 0.0
+		}
+
+		scale := 0.01
+		if path[0] == 2 {
+			scale = 10.0
+		}
+		var value float64
+		for _, move := range path[1:] {
+			value += scale * float64(move-1)
+		}
+		return value
+	}
+
+	// Returns the nodes beneath moves 0 and 2
+	search := func(options ...ExpectimaxOption) (decided, uncertain int) {
+		initNodeMemoryPool()
+		expectimax := NewExpectimax(newEndlessGame(3), heuristic, uniformChildLikelihood, 100000, options...)
+		exploreSteps(expectimax, 300)
+		return expectimax.rootNode.children[0].descendentCount, expectimax.rootNode.children[2].descendentCount
+	}
+
+	t.Run("test the uncertain move gets more of the search", func(t *testing.T) {
+		// Without weighting, ties go to the earlier move
+		if decided, uncertain := search(); uncertain > decided {
+			t.Fatalf("Without weighting, uncertain move had %d nodes and decided move %d, expected no more.", uncertain, decided)
+		}
+		if decided, uncertain := search(WithUncertaintyWeighting(0.5)); uncertain <= 2*decided {
+			t.Errorf("Uncertain move had %d nodes and decided move %d, expected more than double.", uncertain, decided)
+		}
+	})
+}
+
+func TestRootChildFloor(t *testing.T) {
+	t.Run("test every root move gets the floor before best-first takes over", func(t *testing.T) {
+		floor := 100
+		expectimax := NewExpectimax(newEndlessGame(4), endlessHeuristic, maxChildLikelihood, 600, WithRootChildFloor(floor))
+		for expectimax.rootNode.descendentCount < expectimax.maxNodeCount {
+			node := expectimax.getUnexploredNode()
+			node.incrementReference()
+			node.setWaitingForExploration()
+			node.Explore(expectimax.settings)
+			expectimax.processExploredNode(node)
+			node.decrementReference()
+		}
+
+		for move, childNode := range expectimax.rootNode.children {
+			if childNode.descendentCount < floor {
+				t.Errorf("Move %v has %d nodes beneath it, expected at least the floor of %d.", move, childNode.descendentCount, floor)
+			}
+		}
+	})
+}
+
+func TestFocusOn(t *testing.T) {
+	t.Run("test a focused move's subtree grows faster than its siblings'", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 5)), testHeuristic, uniformChildLikelihood, 1000)
+		exploreSteps(expectimax, 1)
+		expectimax.FocusOn(2, 10.0)
+		exploreSteps(expectimax, 30)
+
+		focusedCount := expectimax.rootNode.children[2].descendentCount
+		for _, move := range []int{0, 1} {
+			if count := expectimax.rootNode.children[move].descendentCount; count >= focusedCount {
+				t.Errorf("Move %d has %d descendents, expected fewer than the focused move's %d.", move, count, focusedCount)
+			}
+		}
+
+		expectimax.ClearFocus()
+		if probabilities := expectimax.rootNode.childExploreProbability; probabilities[2] != probabilities[0] {
+			t.Errorf("Explore probabilities were %v after ClearFocus(), expected them all equal.", probabilities)
+		}
+	})
+}
+
+func TestExplorationOrder(t *testing.T) {
+	// Reports the first depth with an unexplored node while a deeper one has been
+	// explored, or -1 if the tree was explored level by level
+	firstSkippedDepth := func(expectimax *Expectimax) int {
+		unexplored, explored := map[int]bool{}, map[int]bool{}
+		expectimax.Walk(func(depth int, move interface{}, value, heuristic float64, status string) bool {
+			if status == Unexplored.String() {
+				unexplored[depth] = true
+			} else {
+				explored[depth] = true
+			}
+			return true
+		})
+
+		for depth := 0; depth < len(explored); depth++ {
+			if unexplored[depth] && explored[depth+1] {
+				return depth
+			}
+		}
+		return -1
+	}
+	// A tree in which move 0 is always best, for best-first search to follow
+	var skewedTree func(depth int) *testState
+	skewedTree = func(depth int) *testState {
+		state := &testState{}
+		for i := 0; depth > 0 && i < 3; i++ {
+			childState := skewedTree(depth - 1)
+			if i == 0 {
+				childState.value = 1.0
+			}
+			state.children = append(state.children, childState)
+		}
+		return state
+	}
+
+	t.Run("test BreadthFirst explores each depth before the next", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(skewedTree(6)), testHeuristic, maxChildLikelihood, 1000, WithExplorationOrder(BreadthFirst))
+		for i := 0; i < 20; i++ {
+			expectimax.RunSynchronous(1)
+			if depth := firstSkippedDepth(expectimax); depth >= 0 {
+				t.Fatalf("After %d nodes, depth %d had unexplored nodes while deeper ones were explored.", i+1, depth)
+			}
+		}
+
+		if err := expectimax.AdvanceToState([]interface{}{1}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			expectimax.RunSynchronous(1)
+			if depth := firstSkippedDepth(expectimax); depth >= 0 {
+				t.Fatalf("After %d nodes below the new root, depth %d had unexplored nodes while deeper ones were explored.", i+1, depth)
+			}
+		}
+	})
+
+	t.Run("test BreadthFirst while searching", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, maxChildLikelihood, 1000, WithExplorationOrder(BreadthFirst))
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) >= 1000 && !expectimax.IsCurrentlySearching() }) {
+			t.Fatal("Search didn't use up its budget.")
+		}
+		if depth := expectimax.GetMaxExploredDepth(); depth > 7 {
+			t.Errorf("Breadth-first search of 1000 nodes reached depth %d, expected no more than 7.", depth)
+		}
+	})
+
+	t.Run("test BestFirst goes deep down the likeliest line", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(skewedTree(6)), testHeuristic, maxChildLikelihood, 1000)
+		expectimax.RunSynchronous(10)
+		if firstSkippedDepth(expectimax) < 0 {
+			t.Errorf("BestFirst explored the tree level by level, expected it to go deep first.")
+		}
+	})
+}
+
+func TestRunSynchronous(t *testing.T) {
+	t.Run("test RunSynchronous() explores exactly the budget", func(t *testing.T) {
+		initNodeMemoryPool()
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 100000)
+		nextMoveValues := expectimax.RunSynchronous(50)
+
+		if nodeCount := getNodeCount(expectimax); nodeCount != 50*3 {
+			t.Errorf("Search has %d nodes after exploring 50, expected %d.", nodeCount, 50*3)
+		}
+		if len(*nextMoveValues) != 3 {
+			t.Errorf("RunSynchronous() returned %d move values, expected 3.", len(*nextMoveValues))
+		}
+	})
+
+	t.Run("test runs with the same budget and seed return the same values", func(t *testing.T) {
+		initNodeMemoryPool()
+		run := func() *extensions.ValueMap {
+			expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 100000, WithRand(rand.New(rand.NewSource(1))))
+			return expectimax.RunSynchronous(500)
+		}
+
+		if first, second := run(), run(); !reflect.DeepEqual(first, second) {
+			t.Errorf("Runs returned %v and %v, expected the same values.", first, second)
+		}
+	})
+
+	t.Run("test RunSynchronous() stops once the tree is fully explored", func(t *testing.T) {
+		initNodeMemoryPool()
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 2)), testHeuristic, uniformChildLikelihood, 1000)
+		expectimax.RunSynchronous(100)
+
+		if !expectimax.IsFullyExplored() {
+			t.Errorf("Tree isn't fully explored after a budget larger than it.")
+		}
+	})
+}
+
+func TestEnsureRootExplored(t *testing.T) {
+	root := branch(0.0,
+		branch(1.0, leaf(2.0), leaf(-3.0)),
+		branch(-1.0, leaf(1.0), leaf(1.5)),
+	)
+
+	t.Run("test EnsureRootExplored() gives the root its children", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		expectimax.EnsureRootExplored()
+
+		if len(expectimax.rootNode.children) != 2 {
+			t.Errorf("Root has %d children after EnsureRootExplored(), expected 2.", len(expectimax.rootNode.children))
+		}
+	})
+
+	t.Run("test WithRootExploredOnCreate() explores the root and RunExpectimax() keeps it", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000, WithRootExploredOnCreate(true))
+		defer expectimax.Stop()
+
+		rootNode := expectimax.rootNode
+		if len(rootNode.children) != 2 {
+			t.Fatalf("Root has %d children after creation, expected 2.", len(rootNode.children))
+		}
+
+		go expectimax.RunExpectimax()
+		if move := expectimax.GetBestMove(); move != 0 {
+			t.Errorf("GetBestMove() was %v, expected 0.", move)
+		}
+		if expectimax.rootNode != rootNode {
+			t.Errorf("RunExpectimax() replaced the explored root.")
+		}
+	})
+}
+
+func TestStopReason(t *testing.T) {
+	root := uniformTree(3, 3)
+
+	t.Run("test a search still going isn't stopped", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		exploreSteps(expectimax, 1)
+
+		if reason := expectimax.StopReason(); reason != NotStopped {
+			t.Errorf("StopReason() was %v, expected NotStopped.", reason)
+		}
+	})
+
+	t.Run("test hitting the node cap is budget reached", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 5)
+		exploreSteps(expectimax, 2)
+
+		if reason := expectimax.StopReason(); reason != BudgetReached {
+			t.Errorf("StopReason() was %v, expected BudgetReached.", reason)
+		}
+	})
+
+	t.Run("test exploring everything is fully explored", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 5)
+		exploreAll(expectimax)
+
+		if reason := expectimax.StopReason(); reason != FullyExplored {
+			t.Errorf("StopReason() was %v, expected FullyExplored over the exceeded budget.", reason)
+		}
+	})
+
+	t.Run("test calling Stop is stopped", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		expectimax.Stop()
+
+		if reason := expectimax.StopReason(); reason != Stopped {
+			t.Errorf("StopReason() was %v, expected Stopped.", reason)
+		}
+	})
+
+	t.Run("test a finished game is game over", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(leaf(1.0)), testHeuristic, uniformChildLikelihood, 1000)
+
+		if reason := expectimax.StopReason(); reason != GameOver {
+			t.Errorf("StopReason() was %v, expected GameOver.", reason)
+		}
+	})
+
+	t.Run("test the stop condition is cancelled", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000,
+			WithStopCondition(func(stats SearchStats) bool { return true }))
+		exploreSteps(expectimax, 1)
+
+		if reason := expectimax.StopReason(); reason != Cancelled {
+			t.Errorf("StopReason() was %v, expected Cancelled.", reason)
+		}
+	})
+
+	t.Run("test a running search reports its reason once idle", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, func() bool { return expectimax.StopReason() == FullyExplored }) {
+			t.Errorf("StopReason() was %v, expected FullyExplored once the tree was searched.", expectimax.StopReason())
+		}
+	})
+}
+
+func TestIsFullyExplored(t *testing.T) {
+	t.Run("test a small game is reported fully explored with its exact value", func(t *testing.T) {
+		root := branch(0.0,
+			branch(1.0, leaf(2.0), leaf(-3.0)),
+			branch(-1.0, leaf(1.0), leaf(1.5)),
+		)
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		defer expectimax.Stop()
+		if expectimax.IsFullyExplored() {
+			t.Errorf("IsFullyExplored() was true before searching.")
+		}
+
+		go expectimax.RunExpectimax()
+		if !waitFor(5*time.Second, expectimax.IsFullyExplored) {
+			t.Fatalf("IsFullyExplored() never became true.")
+		}
+		if value := expectimax.GetValue(); value != 2.0 {
+			t.Errorf("GetValue() was %g, expected the game's value of 2.", value)
+		}
+	})
+
+	t.Run("test a game larger than the budget is never fully explored", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 100)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if waitFor(100*time.Millisecond, expectimax.IsFullyExplored) {
+			t.Errorf("IsFullyExplored() was true for an endless game.")
+		}
+	})
+}
+
+func TestIsConverging(t *testing.T) {
+	t.Run("test a tiny fully explored game has converged", func(t *testing.T) {
+		root := branch(0.0,
+			branch(1.0, leaf(2.0), leaf(-3.0)),
+			branch(-1.0, leaf(1.0), leaf(1.5)),
+		)
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		if !expectimax.IsConverging() {
+			t.Errorf("IsConverging() was false for a fully explored game.")
+		}
+	})
+
+	t.Run("test a search hasn't converged before filling its window", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 6)), testHeuristic, uniformChildLikelihood, 1000)
+		exploreSteps(expectimax, 10)
+
+		if expectimax.IsConverging() {
+			t.Errorf("IsConverging() was true after 10 of the default 100 nodes.")
+		}
+	})
+
+	t.Run("test WithConvergence() sets the window and threshold", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 6)), testHeuristic, uniformChildLikelihood, 1000, WithConvergence(3, 10.0))
+		exploreSteps(expectimax, 2)
+		if expectimax.IsConverging() {
+			t.Errorf("IsConverging() was true after 2 of 3 nodes.")
+		}
+
+		exploreSteps(expectimax, 1)
+		if !expectimax.IsConverging() {
+			t.Errorf("IsConverging() was false with every change within the threshold.")
+		}
+	})
+}
+
+func TestExhaustedPolicy(t *testing.T) {
+	newRoot := func() *testState {
+		return branch(0.0,
+			branch(1.0, leaf(2.0), leaf(-3.0)),
+			branch(-1.0, leaf(1.0), leaf(1.5)),
+		)
+	}
+	allWorkersIdle := func(expectimax *Expectimax) func() bool {
+		return func() bool {
+			return atomic.LoadInt32(&expectimax.idleWorkerCount) == int32(expectimaxWorkerCount)
+		}
+	}
+
+	t.Run("test workers stop once a tiny game is solved, and start again after Restart()", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(newRoot()), testHeuristic, maxChildLikelihood, 1000)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, allWorkersIdle(expectimax)) {
+			t.Fatalf("Only %d of %d workers stopped after the game was solved.", atomic.LoadInt32(&expectimax.idleWorkerCount), expectimaxWorkerCount)
+		}
+		if expectimax.IsCurrentlySearching() {
+			t.Errorf("IsCurrentlySearching() was true for a solved game.")
+		}
+
+		expectimax.Restart(newTestGame(uniformTree(2, 3)))
+		if !waitFor(5*time.Second, expectimax.IsFullyExplored) {
+			t.Fatalf("The workers didn't explore the restarted game.")
+		}
+	})
+
+	t.Run("test PollWhenExhausted keeps the workers polling", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(newRoot()), testHeuristic, maxChildLikelihood, 1000, WithExhaustedPolicy(PollWhenExhausted))
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, expectimax.IsFullyExplored) {
+			t.Fatalf("The game was never solved.")
+		}
+		if waitFor(50*time.Millisecond, func() bool { return atomic.LoadInt32(&expectimax.idleWorkerCount) != 0 }) {
+			t.Errorf("Workers were parked with PollWhenExhausted.")
+		}
+	})
+}
+
+func TestConfidence(t *testing.T) {
+	t.Run("test a fully explored tree is fully trusted", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 3)), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		if confidence := expectimax.Confidence(); confidence != 1.0 {
+			t.Errorf("Confidence() was %g for a fully explored tree, expected 1.", confidence)
+		}
+	})
+
+	t.Run("test a barely started search has little confidence", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 1000)
+		if confidence := expectimax.Confidence(); confidence != 0.0 {
+			t.Errorf("Confidence() was %g before searching, expected 0.", confidence)
+		}
+
+		exploreSteps(expectimax, 2)
+		if confidence := expectimax.Confidence(); confidence <= 0.0 || confidence > 0.01 {
+			t.Errorf("Confidence() was %g after 2 nodes of 1000, expected it small but positive.", confidence)
+		}
+	})
+}
+
+func TestExploreTimeout(t *testing.T) {
+	t.Run("test a hanging heuristic doesn't hold up the search", func(t *testing.T) {
+		root := uniformTree(3, 3)
+		hangingState := root.children[0].children[0]
+		release := make(chan struct{})
+		defer close(release)
+		hangingHeuristic := func(game Game) float64 {
+			if game.(testStateGame).state() == hangingState {
+				<-release
+			}
+			return testHeuristic(game)
+		}
+
+		errors := make(chan error, 10)
+		expectimax := NewExpectimax(newTestGame(root), hangingHeuristic, uniformChildLikelihood, 1000,
+			WithExploreTimeout(20*time.Millisecond), WithErrorCallback(func(err error) { errors <- err }), WithSearchTimeout(5*time.Second))
+		defer expectimax.Stop()
+
+		start := time.Now()
+		result := expectimax.Search()
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Search() took %v with a hanging heuristic.", elapsed)
+		}
+
+		// Everything but the abandoned node's 12 descendents
+		if result.NodeCount != 27 {
+			t.Errorf("Search() explored %d nodes, expected 27.", result.NodeCount)
+		}
+		if len(errors) != 1 {
+			t.Errorf("Got %d errors, expected one for the abandoned node.", len(errors))
+		}
+
+		abandonedNode := expectimax.rootNode.children[0]
+		if len(abandonedNode.children) != 0 || abandonedNode.value != abandonedNode.heuristic || abandonedNode.explorationStatus != Archived {
+			t.Errorf("Abandoned node has %d children and value %g, expected an archived leaf worth its heuristic %g.", len(abandonedNode.children), abandonedNode.value, abandonedNode.heuristic)
+		}
+	})
+
+	t.Run("test an abandoned explore keeps the heuristic it started with", func(t *testing.T) {
+		root := uniformTree(3, 3)
+		hangingState, lastState := root.children[0].children[0], root.children[0].children[2]
+		release := make(chan struct{})
+		// Which heuristic the abandoned explore valued its last child with
+		lastHeuristic := make(chan string, 2)
+		hangingHeuristic := func(game Game) float64 {
+			switch game.(testStateGame).state() {
+			case hangingState:
+				<-release
+			case lastState:
+				lastHeuristic <- "old"
+			}
+			return testHeuristic(game)
+		}
+
+		expectimax := NewExpectimax(newTestGame(root), hangingHeuristic, uniformChildLikelihood, 1000,
+			WithExploreTimeout(20*time.Millisecond), WithErrorCallback(func(err error) {}), WithSearchTimeout(5*time.Second))
+		defer expectimax.Stop()
+		expectimax.Search()
+
+		expectimax.SetHeuristic(func(game Game) float64 {
+			if game.(testStateGame).state() == lastState {
+				lastHeuristic <- "new"
+			}
+			return testHeuristic(game)
+		})
+		close(release)
+
+		select {
+		case heuristic := <-lastHeuristic:
+			if heuristic != "old" {
+				t.Errorf("The abandoned explore went on with the %s heuristic, expected the old one.", heuristic)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The abandoned explore never finished.")
+		}
+	})
+
+	t.Run("test an abandoned explore doesn't store into a table cleared since", func(t *testing.T) {
+		root := uniformTree(3, 3)
+		var nextHash uint64
+		var hashStates func(state *testState)
+		hashStates = func(state *testState) {
+			nextHash++
+			state.hash = nextHash
+			for _, child := range state.children {
+				hashStates(child)
+			}
+		}
+		hashStates(root)
+		hangingState, lastState := root.children[0].children[0], root.children[0].children[2]
+		release := make(chan struct{})
+		hangingHeuristic := func(game Game) float64 {
+			if game.(testStateGame).state() == hangingState {
+				<-release
+			}
+			return testHeuristic(game)
+		}
+
+		expectimax := NewExpectimax(&hashedTestGame{newTestGame(root)}, hangingHeuristic, uniformChildLikelihood, 1000,
+			WithExploreTimeout(20*time.Millisecond), WithErrorCallback(func(err error) {}), WithSearchTimeout(5*time.Second), WithTranspositionTable(1000))
+		defer expectimax.Stop()
+		expectimax.Search()
+
+		expectimax.SetHeuristic(testHeuristic)
+		close(release)
+
+		table := expectimax.settings.transpositions
+		if waitFor(100*time.Millisecond, func() bool { _, ok := table.lookup(lastState.hash, nil); return ok }) {
+			t.Errorf("The abandoned explore stored its old heuristic value after the table was cleared.")
+		}
+	})
+}
+
+func TestMoveValidation(t *testing.T) {
+	t.Run("test an invalid generated move is flagged and left out of the tree", func(t *testing.T) {
+		root := branch(0.0,
+			branch(1.0, leaf(2.0), leaf(-3.0)),
+			branch(-1.0, leaf(1.0), leaf(1.5)),
+		)
+
+		errors := make(chan error, 10)
+		expectimax := NewExpectimax(&invalidMoveTestGame{newTestGame(root)}, testHeuristic, maxChildLikelihood, 1000,
+			WithMoveValidation(true), WithErrorCallback(func(err error) { errors <- err }))
+		exploreAll(expectimax)
+
+		if len(expectimax.rootNode.children) != 2 {
+			t.Errorf("Root has %d children, expected the 2 valid moves.", len(expectimax.rootNode.children))
+		}
+		if _, ok := expectimax.rootNode.children[2]; ok {
+			t.Errorf("Invalid move 2 was given a child.")
+		}
+		if len(errors) != 1 {
+			t.Fatalf("Got %d errors, expected one for the invalid move.", len(errors))
+		}
+		if err := <-errors; !strings.Contains(err.Error(), "[2]") {
+			t.Errorf("Error %q doesn't name the invalid move.", err)
+		}
+	})
+}
+
+func TestMakeMoveErrors(t *testing.T) {
+	t.Run("test a move MakeMove rejects is reported and left out of the tree", func(t *testing.T) {
+		root := branch(0.0,
+			branch(1.0, leaf(2.0), leaf(-3.0)),
+			branch(-1.0, leaf(1.0), leaf(1.5)),
+		)
+
+		errors := make(chan error, 10)
+		expectimax := NewExpectimax(&invalidMoveTestGame{newTestGame(root)}, testHeuristic, maxChildLikelihood, 1000,
+			WithErrorCallback(func(err error) { errors <- err }))
+		exploreAll(expectimax)
+
+		if _, ok := expectimax.rootNode.children[2]; ok {
+			t.Errorf("Move 2, which MakeMove rejects, was given a child.")
+		}
+		if len(expectimax.rootNode.children) != 2 {
+			t.Errorf("Root has %d children, expected the 2 moves MakeMove accepts.", len(expectimax.rootNode.children))
+		}
+		if len(errors) != 1 {
+			t.Fatalf("Got %d errors, expected one for the rejected move.", len(errors))
+		}
+		if err := <-errors; !strings.Contains(err.Error(), "invalid move 2") {
+			t.Errorf("Error %q doesn't pass on MakeMove's error.", err)
+		}
+	})
+}
+
+func TestExploredNodeBufferSize(t *testing.T) {
+	t.Run("test a full buffer blocks workers without losing nodes", func(t *testing.T) {
+		slowMainLoop := func(move interface{}, childCount int, value float64) {
+			time.Sleep(time.Millisecond)
+		}
+
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 3)), testHeuristic, uniformChildLikelihood, 1000,
+			WithExploredNodeBufferSize(1), WithOnExplore(slowMainLoop), WithSearchTimeout(5*time.Second))
+		defer expectimax.Stop()
+
+		result := expectimax.Search()
+		if cap(expectimax.exploredNodeChannel) != 1 {
+			t.Errorf("Explored node buffer holds %d, expected 1.", cap(expectimax.exploredNodeChannel))
+		}
+		if result.NodeCount != 39 || expectimax.stats.getExploredNodes() != 13 {
+			t.Errorf("Search() reached %d nodes exploring %d, expected all 39 reached and 13 explored.", result.NodeCount, expectimax.stats.getExploredNodes())
+		}
+	})
+}
+
+func TestMoveListenerBufferSize(t *testing.T) {
+	t.Run("test a flood of moves during a synchronous search is followed in full", func(t *testing.T) {
+		initNodeMemoryPool()
+		game := newEndlessGame(2)
+		expectimax := NewExpectimax(game, endlessHeuristic, uniformChildLikelihood, 100000, WithMoveListenerBufferSize(1))
+		defer expectimax.Stop()
+
+		started := make(chan struct{})
+		done := make(chan struct{})
+		expectimax.EnsureRootExplored()
+		go func() {
+			close(started)
+			for i := 0; i < 20; i++ {
+				game.MakeMove(i % 2)
+			}
+			close(done)
+		}()
+
+		<-started
+		deadline := time.Now().Add(5 * time.Second)
+		for flooded := false; !flooded; {
+			select {
+			case <-done:
+				flooded = true
+			default:
+				if time.Now().After(deadline) {
+					t.Fatalf("The game was still blocked on its move listener during the synchronous search.")
+				}
+				expectimax.RunSynchronous(10)
+			}
+		}
+
+		go expectimax.RunExpectimax()
+
+		depth := func() int {
+			var depth int
+			expectimax.runOnMainLoop(func() {
+				depth = len(expectimax.rootNode.GetGame().(*endlessGame).path)
+			})
+			return depth
+		}
+		if !waitFor(5*time.Second, func() bool { return depth() == 20 }) {
+			t.Errorf("Root followed %d moves, expected all 20.", depth())
+		}
+	})
+}
+
+func TestNewExpectimaxFromRoot(t *testing.T) {
+	// The game's tree, whose own values the spec overrides
+	game := newTestGame(uniformTree(2, 2))
+	spec := &TreeSpec{Children: []*TreeSpec{
+		{Move: 0, Heuristic: 1.0, Children: []*TreeSpec{{Move: 0, Heuristic: 2.0, Terminal: true}, {Move: 1, Heuristic: -3.0, Terminal: true}}},
+		{Move: 1, Heuristic: -1.0, Children: []*TreeSpec{{Move: 0, Heuristic: 4.0, Terminal: true}, {Move: 1, Heuristic: 1.5, Terminal: true}}},
+	}}
+
+	t.Run("test the built tree is backed up from its leaves", func(t *testing.T) {
+		expectimax := NewExpectimaxFromRoot(game, spec, testHeuristic, maxChildLikelihood, 1000)
+
+		if value := expectimax.GetValue(); value != 4.0 {
+			t.Errorf("GetValue() was %g, expected the best leaf's 4.", value)
+		}
+		if principalVariation := expectimax.GetPrincipalVariation(); len(principalVariation) != 2 || principalVariation[0] != 1 || principalVariation[1] != 0 {
+			t.Errorf("GetPrincipalVariation() was %v, expected [1 0].", principalVariation)
+		}
+		if nodeCount := expectimax.GetNodeCount(); nodeCount != 6 {
+			t.Errorf("GetNodeCount() was %d, expected the 6 nodes below the root.", nodeCount)
+		}
+		if !expectimax.IsFullyExplored() {
+			t.Errorf("A tree of terminal leaves wasn't fully explored.")
+		}
+	})
+
+	t.Run("test unexplored leaves are left for the search", func(t *testing.T) {
+		expectimax := NewExpectimaxFromRoot(game, &TreeSpec{Children: []*TreeSpec{{Move: 0, Heuristic: 1.0}, {Move: 1, Heuristic: 2.0}}}, testHeuristic, maxChildLikelihood, 1000)
+		if expectimax.IsFullyExplored() {
+			t.Fatalf("A tree with unexplored leaves was fully explored.")
+		}
+
+		exploreAll(expectimax)
+		if nodeCount := expectimax.GetNodeCount(); nodeCount != 6 {
+			t.Errorf("GetNodeCount() was %d after exploring the leaves, expected 6.", nodeCount)
+		}
+	})
+}
+
+func TestExportTree(t *testing.T) {
+	root := branch(0.0,
+		branch(1.0, leaf(2.0), leaf(-3.0)),
+		branch(-1.0, leaf(1.0), leaf(1.5)),
+	)
+
+	t.Run("test the exported tree matches the search", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		treeNode := expectimax.ExportTree(-1)
+		if treeNode.Value != expectimax.GetValue() || treeNode.Move != nil || treeNode.Likelihood != 1.0 {
+			t.Errorf("Exported root was %+v, expected value %g with no move and likelihood 1.", treeNode, expectimax.GetValue())
+		}
+		if len(treeNode.Children) != 2 {
+			t.Fatalf("Exported root has %d children, expected 2.", len(treeNode.Children))
+		}
+		for _, child := range treeNode.Children {
+			if len(child.Children) != 2 {
+				t.Errorf("Exported child %v has %d children, expected 2.", child.Move, len(child.Children))
+			}
+			if child.Heuristic != root.children[child.Move.(int)].value || child.Likelihood != expectimax.rootNode.childLikelihood[child.Move] {
+				t.Errorf("Exported child %+v doesn't match the search tree.", child)
+			}
+		}
+	})
+
+	t.Run("test maxDepth limits the export", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		treeNode := expectimax.ExportTree(1)
+		if len(treeNode.Children) != 2 || len(treeNode.Children[0].Children) != 0 {
+			t.Errorf("ExportTree(1) went deeper than one move.")
+		}
+	})
+}
+
+func TestSkipReasons(t *testing.T) {
+	t.Run("test nodes past the depth limit report it", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 3)), testHeuristic, uniformChildLikelihood, 1000, WithMaxDepth(1))
+		exploreAll(expectimax)
+
+		treeNode := expectimax.ExportTree(-1)
+		if treeNode.SkipReason != NotSkipped {
+			t.Errorf("Explored root reports %v, expected NotSkipped.", treeNode.SkipReason)
+		}
+		for _, child := range treeNode.Children {
+			if child.SkipReason != SkippedForDepth {
+				t.Errorf("Child %v past the depth limit reports %v, expected SkippedForDepth.", child.Move, child.SkipReason)
+			}
+		}
+	})
+
+	t.Run("test repeated positions report it", func(t *testing.T) {
+		root := &testState{value: 5.0, hash: 1}
+		repeat := &testState{value: 5.0, hash: 2, children: []*testState{root}}
+		root.children = []*testState{repeat, {value: -1.0, hash: 3}}
+
+		expectimax := NewExpectimax(&hashedTestGame{newTestGame(root)}, testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		if reason := expectimax.rootNode.children[0].children[0].skipReason; reason != SkippedAsRepeat {
+			t.Errorf("Repeated position reports %v, expected SkippedAsRepeat.", reason)
+		}
+		if reason := expectimax.rootNode.children[1].skipReason; reason != NotSkipped {
+			t.Errorf("Explored position reports %v, expected NotSkipped.", reason)
+		}
+	})
+
+	t.Run("test moves left under a proven node report it", func(t *testing.T) {
+		won := &testState{value: 1.0, outcome: WinOutcome}
+		game := &outcomeTestGame{&terminalTestGame{newTestGame(playerBranch(0, 0.0, uniformTree(2, 3), won))}}
+		expectimax := NewExpectimax(game, testHeuristic, maxChildLikelihood, 1000, WithAlternatingPerspective(true), WithProofPropagation(true))
+		exploreAll(expectimax)
+
+		treeNode := expectimax.ExportTree(-1)
+		if len(treeNode.Children) != 2 {
+			t.Fatalf("Proven root exported %d moves, expected 2.", len(treeNode.Children))
+		}
+		for _, child := range treeNode.Children {
+			if expected := map[interface{}]SkipReason{0: SkippedAsProven, 1: NotSkipped}[child.Move]; child.SkipReason != expected {
+				t.Errorf("Move %v under the proven root reports %v, expected %v.", child.Move, child.SkipReason, expected)
+			}
+		}
+	})
+
+	t.Run("test moves held back by widening report it", func(t *testing.T) {
+		expectimax := NewExpectimax(&priorityEndlessGame{newEndlessGame(1000)}, priorityEndlessHeuristic, uniformChildLikelihood, 100000, WithProgressiveWidening(5, 10))
+		exploreSteps(expectimax, 1)
+
+		widening := map[interface{}]bool{}
+		for _, child := range expectimax.ExportTree(1).Children {
+			if child.SkipReason == SkippedForWidening {
+				widening[child.Move] = true
+			}
+		}
+		if len(widening) != 995 || widening[999] {
+			t.Errorf("%d moves report SkippedForWidening, expected the 995 low priority ones.", len(widening))
+		}
+	})
+
+	t.Run("test the node next in line when the budget runs out reports it", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 50)
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) >= 50 && !expectimax.IsCurrentlySearching() }) {
+			t.Fatal("Search didn't use up its budget.")
+		}
+
+		if countBudgetSkips(expectimax.ExportTree(-1)) == 0 {
+			t.Errorf("No node reports SkippedForBudget after the budget ran out.")
+		}
+	})
+
+	t.Run("test the budget skip clears once the root moves on", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 50)
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, func() bool { return getNodeCount(expectimax) >= 50 && !expectimax.IsCurrentlySearching() }) {
+			t.Fatal("Search didn't use up its budget.")
+		}
+		expectimax.Stop()
+
+		var skippedMove interface{}
+		for _, child := range expectimax.ExportTree(-1).Children {
+			if countBudgetSkips(child) > 0 {
+				skippedMove = child.Move
+			}
+		}
+		if skippedMove == nil {
+			t.Fatal("No move's subtree reports SkippedForBudget after the budget ran out.")
+		}
+
+		if err := expectimax.AdvanceToState([]interface{}{skippedMove}); err != nil {
+			t.Fatalf("AdvanceToState failed: %v", err)
+		}
+		if budgetSkips := countBudgetSkips(expectimax.ExportTree(-1)); budgetSkips != 0 {
+			t.Errorf("%d nodes still report SkippedForBudget with %d of 50 nodes used.", budgetSkips, expectimax.GetNodeCount())
+		}
+	})
+}
+
+func countBudgetSkips(treeNode *TreeNode) int {
+	var budgetSkips int
+	if treeNode.SkipReason == SkippedForBudget {
+		budgetSkips++
+	}
+	for _, child := range treeNode.Children {
+		budgetSkips += countBudgetSkips(child)
+	}
+
+	return budgetSkips
+}
+
+func TestOnBudgetReached(t *testing.T) {
+	t.Run("test the callback fires once, when the budget runs out", func(t *testing.T) {
+		var calls int32
+		var reachedAt SearchStats
+		onBudgetReached := func(stats SearchStats) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				reachedAt = stats
+			}
+		}
+
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 30, WithOnBudgetReached(onBudgetReached))
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, func() bool { return atomic.LoadInt32(&calls) > 0 }) {
+			t.Fatalf("Callback never fired.")
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		if calls := atomic.LoadInt32(&calls); calls != 1 {
+			t.Errorf("Callback fired %d times, expected once.", calls)
+		}
+		if reachedAt.NodeCount < 30 {
+			t.Errorf("Callback fired at %d nodes, expected the budget of 30 to be reached.", reachedAt.NodeCount)
+		}
+	})
+}
+
+func TestStopCondition(t *testing.T) {
+	t.Run("test the search halts once the condition holds", func(t *testing.T) {
+		// The root's value passes 0.5 once move 0's replies are seen
+		zeros := func(count int) []*testState {
+			states := make([]*testState, count)
+			for i := range states {
+				states[i] = branch(0.0, leaf(0.0), leaf(0.0))
+			}
+			return states
+		}
+		root := branch(0.0,
+			branch(0.0, leaf(1.0), leaf(1.0)),
+			branch(-1.0, zeros(5)...),
+		)
+
+		var stoppedAt SearchStats
+		stopCondition := func(stats SearchStats) bool {
+			if stats.Value > 0.5 {
+				stoppedAt = stats
+				return true
+			}
+			return false
+		}
+
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000, WithStopCondition(stopCondition), WithSearchTimeout(5*time.Second))
+		defer expectimax.Stop()
+
+		result := expectimax.Search()
+		if result.Value <= 0.5 || stoppedAt.Value != result.Value || stoppedAt.BestMove != 0 {
+			t.Errorf("Search() stopped with value %g and the condition saw %+v, expected both past 0.5 on move 0.", result.Value, stoppedAt)
+		}
+
+		// Explorations already in flight when it stopped may still land
+		time.Sleep(20 * time.Millisecond)
+		exploredNodes := expectimax.stats.getExploredNodes()
+		time.Sleep(50 * time.Millisecond)
+		if expectimax.stats.getExploredNodes() != exploredNodes || expectimax.IsFullyExplored() {
+			t.Errorf("Search kept going after the stop condition held.")
+		}
+	})
+}
+
+func TestRootSelectionPolicy(t *testing.T) {
+	// Move 0 is a lone leaf of higher value, move 1 a deeper branch of lower value.
+	root := branch(0.0, leaf(2.0), branch(1.0, leaf(1.0), leaf(1.0), leaf(1.0)))
+
+	t.Run("test max value picks the highest value", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000, WithRootSelectionPolicy(SelectMaxValue))
+		exploreAll(expectimax)
+
+		if bestMove := expectimax.getBestChildMove(); bestMove != 0 {
+			t.Fatalf("Best move was %v, expected the highest valued move 0.", bestMove)
+		}
+	})
+
+	t.Run("test most visited picks the most searched", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000, WithRootSelectionPolicy(SelectMostVisited))
+		exploreAll(expectimax)
+
+		if bestMove := expectimax.getBestChildMove(); bestMove != 1 {
+			t.Fatalf("Best move was %v, expected the most searched move 1.", bestMove)
+		}
+	})
+}
+
+func TestBestMoveTieBreaking(t *testing.T) {
+	t.Run("test the more explored of equal children is chosen", func(t *testing.T) {
+		root := branch(0.0, leaf(1.0), branch(1.0, leaf(1.0), leaf(1.0)), leaf(1.0))
+
+		for run := 0; run < 10; run++ {
+			expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+			exploreAll(expectimax)
+
+			if bestMove := expectimax.getBestChildMove(); bestMove != 1 {
+				t.Fatalf("Best move was %v, expected the explored move 1.", bestMove)
+			}
+		}
+	})
+
+	t.Run("test remaining ties go to the first listed move", func(t *testing.T) {
+		root := branch(0.0, leaf(1.0), leaf(1.0), leaf(1.0))
+
+		for run := 0; run < 10; run++ {
+			expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000)
+			exploreAll(expectimax)
+
+			if bestMove := expectimax.getBestChildMove(); bestMove != 0 {
+				t.Fatalf("Best move was %v, expected the first listed move 0.", bestMove)
+			}
+		}
+	})
+
+	t.Run("test random tie breaking uses the Rand", func(t *testing.T) {
+		root := branch(0.0, leaf(1.0), leaf(1.0), leaf(1.0))
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000, WithRandomTieBreaking(true), WithRand(&stubRand{values: []float64{0.9}}))
+		exploreAll(expectimax)
+
+		bestMove := expectimax.getBestChildMove()
+		if childNode, ok := expectimax.rootNode.children[bestMove]; !ok || childNode.value != 1.0 {
+			t.Errorf("Best move was %v, expected one of the tied moves.", bestMove)
+		}
+	})
+}
+
+func TestDefaultDeadline(t *testing.T) {
+	t.Run("test node growth halts about the deadline after each re-root", func(t *testing.T) {
+		deadline := 200 * time.Millisecond
+		expectimax := NewExpectimax(newEndlessGame(3), endlessHeuristic, uniformChildLikelihood, 10000000,
+			WithDefaultDeadline(deadline), WithMinResponseNodes(10000000), WithResponsePollInterval(time.Millisecond))
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		start := time.Now()
+		bestMove := expectimax.GetBestMove()
+		if elapsed := time.Since(start); elapsed < deadline || elapsed > 5*deadline {
+			t.Errorf("GetBestMove() took %v, expected about the %v deadline.", elapsed, deadline)
+		}
+
+		// Explorations already in flight at the deadline may still land
+		settled := func() bool {
+			var inFlight int
+			expectimax.runOnMainLoop(func() { inFlight = expectimax.inFlight })
+			return inFlight == 0
+		}
+		if !waitFor(deadline, settled) {
+			t.Fatalf("Explorations were still in flight well after the deadline.")
+		}
+		exploredNodes := expectimax.stats.getExploredNodes()
+		time.Sleep(100 * time.Millisecond)
+		if grown := expectimax.stats.getExploredNodes() - exploredNodes; grown != 0 {
+			t.Errorf("Explored %d more nodes after the deadline, expected none.", grown)
+		}
+
+		expectimax.NotifyMove(bestMove)
+		if !waitFor(deadline/2, func() bool { return expectimax.stats.getExploredNodes() > exploredNodes }) {
+			t.Fatalf("The search didn't start again after the re-root.")
+		}
+
+		time.Sleep(deadline)
+		if !waitFor(deadline, settled) {
+			t.Fatalf("Explorations were still in flight well after the re-rooted search's deadline.")
+		}
+		exploredNodes = expectimax.stats.getExploredNodes()
+		time.Sleep(100 * time.Millisecond)
+		if grown := expectimax.stats.getExploredNodes() - exploredNodes; grown != 0 {
+			t.Errorf("Explored %d more nodes after the re-rooted search's deadline, expected none.", grown)
+		}
+	})
+}
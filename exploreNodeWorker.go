@@ -1,21 +1,49 @@
 package expectimax
 
+import "time"
+
 type exploreNodeWorker struct {
+	id                            int
+	stats                         *workerStatistics // nil unless worker stats are enabled
 	unexploredNodeReceiverChannel chan<- (chan<- *expectimaxNode)
 	exploredNodeChannel           chan<- *expectimaxNode
-	terminate                     bool
+	quit                          <-chan struct{}
 }
 
-func (worker *exploreNodeWorker) ExploreNodeThread(heuristic ExpectimaxHeuristic, calculateChildLikelihoodFunc ExpectimaxChildLikelihoodFunc) {
-	unexploredNodeChannel := make(chan *expectimaxNode)
-	for !worker.terminate {
-		worker.unexploredNodeReceiverChannel <- unexploredNodeChannel
-		parent := <-unexploredNodeChannel
-		parent.Explore(heuristic, calculateChildLikelihoodFunc)
-		worker.exploredNodeChannel <- parent
+func (worker *exploreNodeWorker) ExploreNodeThread(settings *searchSettings) {
+	// Buffered so the main loop's send never blocks on a worker that has quit
+	unexploredNodeChannel := make(chan *expectimaxNode, 1)
+	for {
+		select {
+		case worker.unexploredNodeReceiverChannel <- unexploredNodeChannel:
+		case <-worker.quit:
+			return
+		}
+
+		var parent *expectimaxNode
+		select {
+		case parent = <-unexploredNodeChannel:
+		case <-worker.quit:
+			return
+		}
+
+		// The main loop attaches what's found, so the worker never writes the tree
+		if worker.stats != nil {
+			start := time.Now()
+			parent.found = parent.findExploration(settings)
+			worker.stats.record(time.Since(start))
+		} else {
+			parent.found = parent.findExploration(settings)
+		}
+
+		select {
+		case worker.exploredNodeChannel <- parent:
+		case <-worker.quit:
+			return
+		}
 	}
 }
 
-func NewExploreNodeWorker(unexploredNodeReceiverChannel chan<- (chan<- *expectimaxNode), exploredNodeChannel chan<- *expectimaxNode) *exploreNodeWorker {
-	return &exploreNodeWorker{unexploredNodeReceiverChannel, exploredNodeChannel, false}
+func NewExploreNodeWorker(id int, unexploredNodeReceiverChannel chan<- (chan<- *expectimaxNode), exploredNodeChannel chan<- *expectimaxNode, quit <-chan struct{}) *exploreNodeWorker {
+	return &exploreNodeWorker{id: id, unexploredNodeReceiverChannel: unexploredNodeReceiverChannel, exploredNodeChannel: exploredNodeChannel, quit: quit}
 }
@@ -0,0 +1,77 @@
+package expectimax
+
+// explorationFrontier holds the unexplored nodes for BreadthFirst exploration,
+// in the order they were found. Each node holds a reference while it's queued.
+// The queue is built from the tree when first needed and released whenever the
+// root changes, so it never holds nodes of a discarded tree. It must only be
+// used from the main loop.
+type explorationFrontier struct {
+	nodes []*expectimaxNode
+	built bool
+}
+
+// next returns the first queued node that's still unexplored, dropping those
+// ahead of it that have been explored since they were queued, or nil if there
+// are none. The node stays queued until it's been handed out.
+func (frontier *explorationFrontier) next(root *expectimaxNode) *expectimaxNode {
+	if !frontier.built {
+		frontier.built = true
+		frontier.push(root)
+		frontier.pushDescendents(root)
+	}
+
+	for len(frontier.nodes) > 0 {
+		node := frontier.nodes[0]
+		if node.explorationStatus == Unexplored && !node.isMarkedForDeletion() {
+			return node
+		}
+
+		frontier.nodes[0] = nil
+		frontier.nodes = frontier.nodes[1:]
+		node.decrementReference()
+	}
+
+	return nil
+}
+
+// push queues node if it's unexplored.
+func (frontier *explorationFrontier) push(node *expectimaxNode) {
+	if node.explorationStatus == Unexplored && node.incrementReference() {
+		frontier.nodes = append(frontier.nodes, node)
+	}
+}
+
+// pushChildren queues the unexplored nodes among children.
+func (frontier *explorationFrontier) pushChildren(children []*expectimaxNode) {
+	if !frontier.built {
+		return // They'll be found when the queue is built
+	}
+
+	for _, childNode := range children {
+		frontier.push(childNode)
+	}
+}
+
+// pushDescendents queues the unexplored nodes below node, shallowest first.
+func (frontier *explorationFrontier) pushDescendents(node *expectimaxNode) {
+	level := []*expectimaxNode{node}
+	for len(level) > 0 {
+		var nextLevel []*expectimaxNode
+		for _, levelNode := range level {
+			for _, childNode := range levelNode.orderedChildren {
+				frontier.push(childNode)
+				nextLevel = append(nextLevel, childNode)
+			}
+		}
+		level = nextLevel
+	}
+}
+
+// release drops every queued node, to be built again from the next root.
+func (frontier *explorationFrontier) release() {
+	for _, node := range frontier.nodes {
+		node.decrementReference()
+	}
+	frontier.nodes = nil
+	frontier.built = false
+}
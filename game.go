@@ -13,3 +13,99 @@ type Game interface {
 	RegisterMoveListener(chan<- interface{})
 	Print()
 }
+
+// HashableGame is implemented by games that can summarise their state as a hash.
+// Explore uses it to skip moves that leave the state unchanged or that reach the
+// same state as an earlier sibling, and to value moves that repeat a position
+// further up the path from the root as draws instead of expanding them.
+type HashableGame interface {
+	Game
+	Hash() uint64
+}
+
+// EqualGame is implemented by games that can compare states exactly. For a
+// HashableGame, Explore then only treats states with the same hash as the same
+// when Equal agrees, so a hash collision can't merge different positions. A game
+// that can't be hashed can use Equal to value repeated positions as draws
+// instead, with WithEqualCycleDetection.
+type EqualGame interface {
+	Game
+	Equal(other Game) bool
+}
+
+// MoveOrderingGame is implemented by games that can rank their moves, so that
+// progressive widening adds the most promising moves first.
+type MoveOrderingGame interface {
+	Game
+	GetMovePriority(move interface{}) float64 // Higher is tried sooner
+}
+
+// MultiplayerGame is implemented by games that can report whose turn it is.
+// Player 0 is the player the heuristic scores for.
+type MultiplayerGame interface {
+	Game
+	GetCurrentPlayer() int
+}
+
+// TerminalValueGame is implemented by games that can value a finished game exactly.
+// Children that are game over take their TerminalValue instead of the heuristic
+// estimate when it reports ok.
+type TerminalValueGame interface {
+	Game
+	TerminalValue() (float64, bool)
+}
+
+// Outcome is how a finished game ended for player 0.
+type Outcome int
+
+const (
+	UnknownOutcome Outcome = iota
+	WinOutcome
+	DrawOutcome
+	LossOutcome
+)
+
+// OutcomeGame is implemented by games that can say whether a finished game was
+// won, drawn or lost. With WithOutcomeValues, drawn and lost games take the
+// values given to the option instead of their TerminalValue or heuristic, so the
+// search holds a draw rather than losing whatever the game's own scores say.
+// Won games and UnknownOutcome are valued as usual.
+type OutcomeGame interface {
+	Game
+	GetOutcome() Outcome
+}
+
+// JointMove is the move made at a simultaneous node, one move for each of the
+// two players. Both moves must be comparable so a JointMove can be a map key.
+type JointMove struct {
+	Row    interface{} // Player 0's move
+	Column interface{} // Player 1's move
+}
+
+// SimultaneousGame is implemented by two-player zero-sum games in which both
+// players move at once, such as rock-paper-scissors. Explore expands every
+// pairing of GetPlayerMoves(0) and GetPlayerMoves(1) as a JointMove, which is
+// what MakeMove then receives; GetPossibleMoves is not used. Values are from
+// player 0's point of view, so player 0 maximises and player 1 minimises.
+type SimultaneousGame interface {
+	Game
+	GetPlayerMoves(player int) *extensions.InterfaceSlice
+}
+
+// NodeType distinguishes the players' decisions from random events.
+type NodeType int
+
+const (
+	DecisionNode NodeType = iota
+	ChanceNode
+)
+
+// ChanceGame is implemented by games with random events, such as dice rolls or
+// tile spawns. At a ChanceNode each move is a random outcome; its likelihood is
+// the probability from GetMoveProbability rather than the result of the
+// ExpectimaxChildLikelihoodFunc. Probabilities are normalised over the outcomes.
+type ChanceGame interface {
+	Game
+	GetNodeType() NodeType
+	GetMoveProbability(move interface{}) float64
+}
@@ -0,0 +1,414 @@
+package expectimax
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andrew-j-armstrong/go-extensions"
+)
+
+// testState is a node in a fixed game tree used by the tests. Moves are the
+// indices into children.
+type testState struct {
+	value       float64
+	player      int
+	hash        uint64
+	chance      bool    // Children are random outcomes
+	outcome     Outcome // How the game ended, for outcomeTestGame
+	probability float64 // Probability of this outcome when the parent is a chance node
+	children    []*testState
+}
+
+func leaf(value float64) *testState {
+	return &testState{value: value}
+}
+
+func branch(value float64, children ...*testState) *testState {
+	return &testState{value: value, children: children}
+}
+
+func playerBranch(player int, value float64, children ...*testState) *testState {
+	return &testState{value: value, player: player, children: children}
+}
+
+// testStateGame is satisfied by testGame and the wrappers that embed it.
+type testStateGame interface {
+	state() *testState
+}
+
+type testGame struct {
+	root      *testState
+	path      []int
+	listeners []chan<- interface{}
+}
+
+func newTestGame(root *testState) *testGame {
+	return &testGame{root: root}
+}
+
+func (game *testGame) state() *testState {
+	state := game.root
+	for _, move := range game.path {
+		state = state.children[move]
+	}
+	return state
+}
+
+func (game *testGame) IsGameOver() bool {
+	return len(game.state().children) == 0
+}
+
+func (game *testGame) IsValidMove(move interface{}) bool {
+	index, ok := move.(int)
+	return ok && index >= 0 && index < len(game.state().children)
+}
+
+func (game *testGame) GetPossibleMoves() *extensions.InterfaceSlice {
+	moves := make(extensions.InterfaceSlice, len(game.state().children))
+	for i := range moves {
+		moves[i] = i
+	}
+	return &moves
+}
+
+func (game *testGame) MakeMove(move interface{}) error {
+	if !game.IsValidMove(move) {
+		return fmt.Errorf("invalid move %v", move)
+	}
+
+	game.path = append(game.path, move.(int))
+	for _, listener := range game.listeners {
+		listener <- move
+	}
+	return nil
+}
+
+func (game *testGame) Clone() interface{} {
+	path := make([]int, len(game.path))
+	copy(path, game.path)
+	return &testGame{root: game.root, path: path}
+}
+
+func (game *testGame) RegisterMoveListener(listener chan<- interface{}) {
+	game.listeners = append(game.listeners, listener)
+}
+
+func (game *testGame) GetCurrentPlayer() int {
+	return game.state().player
+}
+
+func (game *testGame) Print() {
+	fmt.Println(game.path)
+}
+
+// invalidMoveTestGame is a testGame whose root lists one move too many.
+type invalidMoveTestGame struct {
+	*testGame
+}
+
+func (game *invalidMoveTestGame) GetPossibleMoves() *extensions.InterfaceSlice {
+	moves := game.testGame.GetPossibleMoves()
+	if len(game.path) == 0 {
+		*moves = append(*moves, len(*moves))
+	}
+	return moves
+}
+
+func (game *invalidMoveTestGame) Clone() interface{} {
+	return &invalidMoveTestGame{game.testGame.Clone().(*testGame)}
+}
+
+// hashedTestGame is a testGame that reports each state's hash field.
+type hashedTestGame struct {
+	*testGame
+}
+
+func (game *hashedTestGame) Clone() interface{} {
+	return &hashedTestGame{game.testGame.Clone().(*testGame)}
+}
+
+func (game *hashedTestGame) Hash() uint64 {
+	return game.state().hash
+}
+
+// equalTestGame is a hashedTestGame whose states are only equal to themselves,
+// whatever their hashes.
+type equalTestGame struct {
+	*hashedTestGame
+}
+
+func (game *equalTestGame) Clone() interface{} {
+	return &equalTestGame{game.hashedTestGame.Clone().(*hashedTestGame)}
+}
+
+func (game *equalTestGame) Equal(other Game) bool {
+	return game.state() == other.(testStateGame).state()
+}
+
+// unhashedEqualTestGame is a testGame whose states can be compared with Equal
+// but not hashed.
+type unhashedEqualTestGame struct {
+	*testGame
+}
+
+func (game *unhashedEqualTestGame) Clone() interface{} {
+	return &unhashedEqualTestGame{game.testGame.Clone().(*testGame)}
+}
+
+func (game *unhashedEqualTestGame) Equal(other Game) bool {
+	return game.state() == other.(testStateGame).state()
+}
+
+func testHeuristic(game Game) float64 {
+	return game.(testStateGame).state().value
+}
+
+func maxChildLikelihood(getGame func() Game, getChildValue func(interface{}) float64, childLikelihood *extensions.ValueMap) {
+	if len(*childLikelihood) == 0 {
+		return
+	}
+
+	bestMove := childLikelihood.GetKeys().GetBestEntry(getChildValue)
+	for move := range *childLikelihood {
+		(*childLikelihood)[move] = 0.0
+	}
+	(*childLikelihood)[bestMove] = 1.0
+}
+
+func uniformChildLikelihood(getGame func() Game, getChildValue func(interface{}) float64, childLikelihood *extensions.ValueMap) {
+	for move := range *childLikelihood {
+		(*childLikelihood)[move] = 1.0 / float64(len(*childLikelihood))
+	}
+}
+
+// exploreAll explores every reachable node on the calling goroutine, the way
+// the workers and main loop would.
+func exploreAll(expectimax *Expectimax) {
+	for expectimax.rootNode.mostLikelyUnexploredDescendent != nil {
+		node := expectimax.rootNode.mostLikelyUnexploredDescendent
+		node.incrementReference()
+		node.setWaitingForExploration()
+		node.Explore(expectimax.settings)
+		expectimax.processExploredNode(node)
+		node.decrementReference()
+	}
+}
+
+// countingTestGame is a testGame that counts calls to GetPossibleMoves.
+type countingTestGame struct {
+	*testGame
+	possibleMoveCalls *int
+}
+
+func (game *countingTestGame) Clone() interface{} {
+	return &countingTestGame{game.testGame.Clone().(*testGame), game.possibleMoveCalls}
+}
+
+func (game *countingTestGame) GetPossibleMoves() *extensions.InterfaceSlice {
+	*game.possibleMoveCalls++
+	return game.testGame.GetPossibleMoves()
+}
+
+// uniformTree builds a tree with the given branching factor and depth.
+func uniformTree(branching int, depth int) *testState {
+	state := &testState{value: float64(depth)}
+	if depth > 0 {
+		for i := 0; i < branching; i++ {
+			state.children = append(state.children, uniformTree(branching, depth-1))
+		}
+	}
+	return state
+}
+
+// terminalTestGame is a testGame whose finished states report their value as exact.
+type terminalTestGame struct {
+	*testGame
+}
+
+func (game *terminalTestGame) Clone() interface{} {
+	return &terminalTestGame{game.testGame.Clone().(*testGame)}
+}
+
+func (game *terminalTestGame) TerminalValue() (float64, bool) {
+	return game.state().value, game.IsGameOver()
+}
+
+// outcomeTestGame is a terminalTestGame whose finished states also report their
+// outcome field.
+type outcomeTestGame struct {
+	*terminalTestGame
+}
+
+func (game *outcomeTestGame) Clone() interface{} {
+	return &outcomeTestGame{game.terminalTestGame.Clone().(*terminalTestGame)}
+}
+
+func (game *outcomeTestGame) GetOutcome() Outcome {
+	return game.state().outcome
+}
+
+// matrixGame is a one-shot simultaneous game with the given payoffs for player 0.
+type matrixGame struct {
+	testGame
+	payoff [][]float64
+	played *JointMove
+}
+
+func (game *matrixGame) IsGameOver() bool {
+	return game.played != nil
+}
+
+func (game *matrixGame) GetPlayerMoves(player int) *extensions.InterfaceSlice {
+	count := len(game.payoff)
+	if player == 1 {
+		count = len(game.payoff[0])
+	}
+
+	moves := make(extensions.InterfaceSlice, count)
+	for i := range moves {
+		moves[i] = i
+	}
+	return &moves
+}
+
+func (game *matrixGame) MakeMove(move interface{}) error {
+	jointMove := move.(JointMove)
+	game.played = &jointMove
+	return nil
+}
+
+func (game *matrixGame) Clone() interface{} {
+	return &matrixGame{payoff: game.payoff, played: game.played}
+}
+
+func matrixHeuristic(game Game) float64 {
+	matrixGame := game.(*matrixGame)
+	if matrixGame.played == nil {
+		return 0.0
+	}
+	return matrixGame.payoff[matrixGame.played.Row.(int)][matrixGame.played.Column.(int)]
+}
+
+// chanceTestGame is a testGame whose chance states pick children by probability.
+type chanceTestGame struct {
+	*testGame
+}
+
+func (game *chanceTestGame) Clone() interface{} {
+	return &chanceTestGame{game.testGame.Clone().(*testGame)}
+}
+
+func (game *chanceTestGame) GetNodeType() NodeType {
+	if game.state().chance {
+		return ChanceNode
+	}
+	return DecisionNode
+}
+
+func (game *chanceTestGame) GetMoveProbability(move interface{}) float64 {
+	return game.state().children[move.(int)].probability
+}
+
+// endlessGame never finishes; every state has the same number of moves.
+type endlessGame struct {
+	testGame
+	branching int
+	path      []int
+}
+
+func newEndlessGame(branching int) *endlessGame {
+	return &endlessGame{branching: branching}
+}
+
+func (game *endlessGame) IsGameOver() bool {
+	return false
+}
+
+func (game *endlessGame) IsValidMove(move interface{}) bool {
+	index, ok := move.(int)
+	return ok && index >= 0 && index < game.branching
+}
+
+func (game *endlessGame) GetPossibleMoves() *extensions.InterfaceSlice {
+	moves := make(extensions.InterfaceSlice, game.branching)
+	for i := range moves {
+		moves[i] = i
+	}
+	return &moves
+}
+
+func (game *endlessGame) MakeMove(move interface{}) error {
+	if !game.IsValidMove(move) {
+		return fmt.Errorf("invalid move %v", move)
+	}
+
+	game.path = append(game.path, move.(int))
+	for _, listener := range game.listeners {
+		listener <- move
+	}
+	return nil
+}
+
+func (game *endlessGame) Clone() interface{} {
+	path := make([]int, len(game.path))
+	copy(path, game.path)
+	return &endlessGame{branching: game.branching, path: path}
+}
+
+func endlessHeuristic(game Game) float64 {
+	value := 0.0
+	for depth, move := range game.(*endlessGame).path {
+		value += float64((move*7+depth*3)%5) - 2.0
+	}
+	return value
+}
+
+// getNodeCount reads the root's descendent count on the main loop.
+func getNodeCount(expectimax *Expectimax) int {
+	var nodeCount int
+	expectimax.runOnMainLoop(func() {
+		nodeCount = expectimax.rootNode.descendentCount
+	})
+	return nodeCount
+}
+
+// waitFor polls condition until it holds or the timeout passes.
+func waitFor(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+// priorityEndlessGame is an endlessGame that ranks higher numbered moves first.
+type priorityEndlessGame struct {
+	*endlessGame
+}
+
+func (game *priorityEndlessGame) Clone() interface{} {
+	return &priorityEndlessGame{game.endlessGame.Clone().(*endlessGame)}
+}
+
+func (game *priorityEndlessGame) GetMovePriority(move interface{}) float64 {
+	return float64(move.(int))
+}
+
+func priorityEndlessHeuristic(game Game) float64 {
+	return endlessHeuristic(game.(*priorityEndlessGame).endlessGame)
+}
+
+// exploreSteps explores up to steps nodes the way exploreAll does.
+func exploreSteps(expectimax *Expectimax, steps int) {
+	for i := 0; i < steps && expectimax.rootNode.mostLikelyUnexploredDescendent != nil; i++ {
+		node := expectimax.rootNode.mostLikelyUnexploredDescendent
+		node.incrementReference()
+		node.setWaitingForExploration()
+		node.Explore(expectimax.settings)
+		expectimax.processExploredNode(node)
+		node.decrementReference()
+	}
+}
@@ -0,0 +1,42 @@
+package expectimax
+
+import (
+	"math"
+
+	"github.com/andrew-j-armstrong/go-extensions"
+)
+
+// NewMinimax creates a search for a two-player zero-sum game whose
+// MultiplayerGame.GetCurrentPlayer alternates between the players. heuristic
+// scores positions for player 0, who picks the highest value while player 1
+// picks the lowest, and the search stops maxDepth moves below the root. It's an
+// Expectimax WithAlternatingPerspective that expects the best reply at every
+// decision node, so options given here can still override either.
+func NewMinimax(game Game, heuristic ExpectimaxHeuristic, maxDepth int, options ...ExpectimaxOption) *Expectimax {
+	options = append([]ExpectimaxOption{WithAlternatingPerspective(true), WithMaxDepth(maxDepth)}, options...)
+	return newExpectimax(game, heuristic, minimaxChildLikelihood, math.MaxInt32, false, options)
+}
+
+// minimaxChildLikelihood expects the move with the best value for the player to
+// move, sharing the likelihood between ties.
+func minimaxChildLikelihood(getGame func() Game, getChildValue func(interface{}) float64, childLikelihood *extensions.ValueMap) {
+	bestValue := math.Inf(-1)
+	bestCount := 0
+	for move := range *childLikelihood {
+		value := getChildValue(move)
+		switch {
+		case value > bestValue:
+			bestValue, bestCount = value, 1
+		case value == bestValue:
+			bestCount++
+		}
+	}
+
+	for move := range *childLikelihood {
+		if getChildValue(move) == bestValue {
+			(*childLikelihood)[move] = 1.0 / float64(bestCount)
+		} else {
+			(*childLikelihood)[move] = 0.0
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package expectimax
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/andrew-j-armstrong/go-extensions"
+)
+
+// ticTacToe is noughts and crosses, with X, player 0, moving first. Moves are
+// the squares 0 to 8, left to right and top to bottom.
+type ticTacToe struct {
+	board     [9]byte
+	listeners []chan<- interface{}
+}
+
+var ticTacToeLines = [][3]int{{0, 1, 2}, {3, 4, 5}, {6, 7, 8}, {0, 3, 6}, {1, 4, 7}, {2, 5, 8}, {0, 4, 8}, {2, 4, 6}}
+
+// newTicTacToe sets up a board from a row-major string of 'X', 'O' and '.'.
+func newTicTacToe(board string) *ticTacToe {
+	game := &ticTacToe{}
+	copy(game.board[:], board)
+	return game
+}
+
+func (game *ticTacToe) winner() byte {
+	for _, line := range ticTacToeLines {
+		if mark := game.board[line[0]]; mark != '.' && mark == game.board[line[1]] && mark == game.board[line[2]] {
+			return mark
+		}
+	}
+	return '.'
+}
+
+func (game *ticTacToe) IsGameOver() bool {
+	return game.winner() != '.' || len(*game.GetPossibleMoves()) == 0
+}
+
+func (game *ticTacToe) IsValidMove(move interface{}) bool {
+	square, ok := move.(int)
+	return ok && square >= 0 && square < 9 && game.board[square] == '.'
+}
+
+func (game *ticTacToe) GetPossibleMoves() *extensions.InterfaceSlice {
+	moves := extensions.InterfaceSlice{}
+	if game.winner() == '.' {
+		for square, mark := range game.board {
+			if mark == '.' {
+				moves = append(moves, square)
+			}
+		}
+	}
+	return &moves
+}
+
+func (game *ticTacToe) MakeMove(move interface{}) error {
+	if !game.IsValidMove(move) {
+		return fmt.Errorf("invalid move %v", move)
+	}
+
+	game.board[move.(int)] = "XO"[game.GetCurrentPlayer()]
+	for _, listener := range game.listeners {
+		listener <- move
+	}
+	return nil
+}
+
+func (game *ticTacToe) Clone() interface{} {
+	return &ticTacToe{board: game.board}
+}
+
+func (game *ticTacToe) RegisterMoveListener(listener chan<- interface{}) {
+	game.listeners = append(game.listeners, listener)
+}
+
+func (game *ticTacToe) GetCurrentPlayer() int {
+	marks := 0
+	for _, mark := range game.board {
+		if mark != '.' {
+			marks++
+		}
+	}
+	return marks % 2
+}
+
+func (game *ticTacToe) Print() {
+	fmt.Println(string(game.board[:]))
+}
+
+func ticTacToeHeuristic(game Game) float64 {
+	switch game.(*ticTacToe).winner() {
+	case 'X':
+		return 1.0
+	case 'O':
+		return -1.0
+	}
+	return 0.0
+}
+
+func TestNewMinimax(t *testing.T) {
+	tests := []struct {
+		name     string
+		board    string
+		bestMove int
+		value    float64
+	}{
+		{"test X takes the win rather than blocking", "XX.OO....", 2, 1.0},
+		{"test O blocks the only threat", "X.X.O....", 1, 0.0},
+		{"test O answers a corner opening in the centre to hold the draw", "X........", 4, 0.0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			initNodeMemoryPool()
+			game := newTicTacToe(test.board)
+			expectimax := NewMinimax(game, ticTacToeHeuristic, len(*game.GetPossibleMoves()))
+			exploreAll(expectimax)
+
+			if bestMove := expectimax.getBestChildMove(); bestMove != test.bestMove {
+				t.Errorf("Best move was %v, expected %d.", bestMove, test.bestMove)
+			}
+			if value := expectimax.rootNode.value; value != test.value {
+				t.Errorf("Root was valued %f, expected %f.", value, test.value)
+			}
+		})
+	}
+
+	t.Run("test the search stops at maxDepth", func(t *testing.T) {
+		initNodeMemoryPool()
+		expectimax := NewMinimax(newTicTacToe("........."), ticTacToeHeuristic, 2)
+		exploreAll(expectimax)
+
+		if nodeCount := getNodeCount(expectimax); nodeCount != 9+9*8 {
+			t.Errorf("Search reached %d nodes, expected %d two moves deep.", nodeCount, 9+9*8)
+		}
+	})
+
+	t.Run("test the search goes on past maxDepth as moves are played", func(t *testing.T) {
+		initNodeMemoryPool()
+		expectimax := NewMinimax(newTicTacToe("........."), ticTacToeHeuristic, 2)
+		for _, move := range []int{4, 0, 2, 6} {
+			expectimax.RunSynchronous(1000)
+			if bestMove := expectimax.getBestChildMove(); bestMove == nil {
+				t.Fatalf("No best move before playing %d.", move)
+			}
+			if _, err := expectimax.Advance(move); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expectimax.RunSynchronous(1000)
+		if bestMove := expectimax.getBestChildMove(); bestMove != 3 {
+			t.Errorf("Best move after four moves was %v, expected 3 to block.", bestMove)
+		}
+	})
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"sync"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/andrew-j-armstrong/go-extensions"
 )
@@ -19,45 +22,93 @@ const (
 	Archived
 )
 
+func (status explorationStatus) String() string {
+	switch status {
+	case Unexplored:
+		return "Unexplored"
+	case WaitingForExploration:
+		return "WaitingForExploration"
+	case Exploring:
+		return "Exploring"
+	case Explored:
+		return "Explored"
+	case Archived:
+		return "Archived"
+	}
+
+	return fmt.Sprintf("explorationStatus(%d)", int(status))
+}
+
 type expectimaxNode struct {
 	game                                     Game
 	parent                                   *expectimaxNode
 	children                                 map[interface{}]*expectimaxNode
+	orderedChildren                          []*expectimaxNode // The children in the order they were added
 	childLikelihood                          extensions.ValueMap
 	childExploreProbability                  extensions.ValueMap
+	exploreWeights                           map[interface{}]float64 // Multipliers on childExploreProbability set by FocusOn, usually nil
+	prior                                    map[interface{}]float64 // From WithPrior, when set
 	explorationStatus                        explorationStatus
 	lastMove                                 interface{}
+	possibleMoves                            *extensions.InterfaceSlice // Cached at creation when possible move caching is enabled
+	simultaneousMoves                        [2][]interface{}           // Each player's moves at a simultaneous node
+	nodeType                                 NodeType
+	hash                                     uint64 // Position hash, when hashed is set
+	hashed                                   bool
+	exploreError                             error         // Set when exploring the node failed, until the main loop reports it
+	found                                    *exploration  // What the worker that explored the node found, until the main loop attaches it
+	pendingMoves                             []interface{} // Moves still to be added by progressive widening
+	takenMoves                               int           // Moves added as children, or collapsed
+	visitCount                               int           // Explorations processed in the subtree
 	heuristic                                float64
 	value                                    float64
+	minValue                                 float64 // Lowest leaf value in the subtree
+	maxValue                                 float64 // Highest leaf value in the subtree
+	valueSpread                              float64 // Standard deviation of the children's values, with uncertainty weighting
+	perspective                              float64 // -1 when the player to move sees values negated
 	mostLikelyUnexploredDescendent           *expectimaxNode
 	mostLikelyUnexploredDescendentLikelihood float64
 	descendentCount                          int
 	averageDepth                             float64
-	referenceCount                           int
-	markedForDeletion                        bool
+	maxDepth                                 int         // Plies in the longest line below the node
+	replayDepth                              int         // Moves GetGame replays from the nearest ancestor with a game
+	userData                                 interface{} // From WithOnNodeCreate, never looked at by the search
+	proof                                    Outcome     // The outcome for player 0 with best play, once proven
+	skipReason                               SkipReason  // Why the search declined to explore the node, if it did
+	referenceCount                           int32       // Updated atomically, as the workers share ancestors
+	markedForDeletion                        int32       // Set atomically by the cleaner, and read by everything
 }
 
-var expectimaxNodeMemoryPool *sync.Pool
+// nodeAllocator supplies every node, the default pool unless SetAllocator has
+// been given another.
+var nodeAllocator Allocator
 
 func initNodeMemoryPool() {
-	if expectimaxNodeMemoryPool == nil {
-		expectimaxNodeMemoryPool = &sync.Pool{
-			New: func() interface{} {
-				node := new(expectimaxNode)
-				node.reset()
-				return node
-			},
-		}
+	if nodeAllocator == nil {
+		nodeAllocator = newPoolAllocator()
 	}
 }
 
 func getNewNode() *expectimaxNode {
-	node := expectimaxNodeMemoryPool.Get().(*expectimaxNode)
-	//node := &expectimaxNode{}
-	//node.reset()
+	node := (*expectimaxNode)(nodeAllocator.Get())
+	if node.children == nil {
+		node.reset() // A new zero node, rather than one that was reset when it was freed
+	}
 	return node
 }
 
+// warmNodeMemoryPool makes sure the pool holds at least count free nodes,
+// allocating any it's short of.
+func warmNodeMemoryPool(count int) {
+	nodes := make([]*expectimaxNode, count)
+	for i := range nodes {
+		nodes[i] = getNewNode()
+	}
+	for _, node := range nodes {
+		nodeAllocator.Put((*Node)(node))
+	}
+}
+
 func (node *expectimaxNode) reset() {
 	if node.mostLikelyUnexploredDescendent != nil && node.mostLikelyUnexploredDescendent != node {
 		node.mostLikelyUnexploredDescendent.decrementReference()
@@ -65,11 +116,13 @@ func (node *expectimaxNode) reset() {
 
 	node.game = nil
 	node.parent = nil
+	node.orderedChildren = node.orderedChildren[:0]
+	// Maps are emptied rather than replaced, so a node from the pool has them
+	// ready for its next Explore without allocating again
 	if node.children != nil {
-		for move, child := range node.children {
-			if child.parent == node {
-				child.parent = nil
-			}
+		// The children may already be back in the pool, so they aren't read.
+		// deleteTree and descendTo have unlinked them from the node
+		for move := range node.children {
 			delete(node.children, move)
 		}
 	} else {
@@ -87,30 +140,53 @@ func (node *expectimaxNode) reset() {
 	}
 	node.explorationStatus = Unexplored
 	node.lastMove = nil
+	node.possibleMoves = nil
+	node.simultaneousMoves = [2][]interface{}{}
+	node.nodeType = DecisionNode
+	node.hash = 0
+	node.hashed = false
+	node.exploreError = nil
+	node.found = nil
+	node.pendingMoves = nil
+	node.takenMoves = 0
+	node.visitCount = 0
+	node.exploreWeights = nil
+	node.prior = nil
 	node.heuristic = 0.0
 	node.value = 0.0
+	node.minValue = 0.0
+	node.maxValue = 0.0
+	node.valueSpread = 0.0
+	node.perspective = 1.0
 	node.mostLikelyUnexploredDescendent = node
 	node.mostLikelyUnexploredDescendentLikelihood = 1.0
 	node.descendentCount = 0
 	node.averageDepth = 0
-	node.referenceCount = 0
-	node.markedForDeletion = false
+	node.maxDepth = 0
+	node.replayDepth = 0
+	node.userData = nil
+	node.proof = UnknownOutcome
+	node.skipReason = NotSkipped
+	atomic.StoreInt32(&node.referenceCount, 0)
+	atomic.StoreInt32(&node.markedForDeletion, 0)
+}
+
+func (node *expectimaxNode) isMarkedForDeletion() bool {
+	return atomic.LoadInt32(&node.markedForDeletion) != 0
 }
 
 func (node *expectimaxNode) incrementReference() bool {
-	// Needs to be atomic
-	if node.markedForDeletion {
+	if node.isMarkedForDeletion() {
 		return false
 	}
-	node.referenceCount++
+	atomic.AddInt32(&node.referenceCount, 1)
 	return true
 }
 
 func (node *expectimaxNode) decrementReference() {
-	node.referenceCount-- // Needs to be atomic
-	if node.referenceCount == 0 && node.markedForDeletion {
+	if atomic.AddInt32(&node.referenceCount, -1) == 0 && node.isMarkedForDeletion() {
 		node.reset()
-		expectimaxNodeMemoryPool.Put(node)
+		nodeAllocator.Put((*Node)(node))
 	}
 }
 
@@ -120,33 +196,102 @@ func (node *expectimaxNode) deleteTree(exemptChildNode *expectimaxNode) {
 	}
 	defer node.decrementReference()
 
-	node.markedForDeletion = true
+	atomic.StoreInt32(&node.markedForDeletion, 1)
 	for _, childNode := range node.children {
-		childNode.parent = nil
 		if childNode != exemptChildNode {
-			childNode.deleteTree(nil)
+			childNode.parent = nil
+			childNode.deleteTree(exemptChildNode)
 		}
 	}
 }
 
-func (node *expectimaxNode) descendToChild(move interface{}) *expectimaxNode {
+// descendTo makes descendent, anywhere below node, the root of its own tree. The
+// rest of node's tree is left for the caller to delete.
+func (node *expectimaxNode) descendTo(descendent *expectimaxNode) *expectimaxNode {
 	if !node.incrementReference() {
 		log.Fatal("Trying to descend to a child after the parent has already been marked for deletion")
 	}
 
-	childNode := node.children[move]
-	if !childNode.incrementReference() {
+	if !descendent.incrementReference() {
 		log.Fatal("Trying to descend to a child after the parent has already been marked for deletion")
 	}
-	defer childNode.decrementReference()
+	defer descendent.decrementReference()
 
-	childNode.game = childNode.GetGame()
-	childNode.parent = nil
+	if descendent.game == nil {
+		descendent.game = descendent.GetGame()
+	}
+	descendent.replayDepth = 0
+	descendent.parent = nil
 
 	node.decrementReference()
-	go node.deleteTree(childNode)
 
-	return childNode
+	return descendent
+}
+
+// depth is the number of moves from the root to the node.
+func (node *expectimaxNode) depth() int {
+	depth := 0
+	for ancestor := node.parent; ancestor != nil; ancestor = ancestor.parent {
+		depth++
+	}
+	return depth
+}
+
+// reopenDepthCuts makes the leaves the depth limit cut off that are now within
+// maxDepth moves of the node, the root, unexplored again, so the search carries
+// on below them once the root has moved down to meet them. depth is the node's
+// own depth. It returns whether any were reopened. It must be called from the
+// main loop.
+func (node *expectimaxNode) reopenDepthCuts(maxDepth int, depth int) bool {
+	if !node.incrementReference() {
+		return false
+	}
+	defer node.decrementReference()
+
+	if node.skipReason == SkippedForDepth {
+		if depth >= maxDepth {
+			return false
+		}
+		node.skipReason = NotSkipped
+		node.explorationStatus = Unexplored
+		node.updateMostLikelyUnexploredDescendent(false, false)
+		return true
+	}
+
+	if node.explorationStatus != Archived {
+		return false
+	}
+
+	reopened := false
+	for _, childNode := range node.orderedChildren {
+		if childNode.reopenDepthCuts(maxDepth, depth+1) {
+			reopened = true
+		}
+	}
+	if reopened {
+		node.updateMostLikelyUnexploredDescendent(false, false)
+	}
+	return reopened
+}
+
+// addChild adds childNode as the node's child for its last move, after any it
+// already has.
+func (node *expectimaxNode) addChild(childNode *expectimaxNode) {
+	node.children[childNode.lastMove] = childNode
+	node.orderedChildren = append(node.orderedChildren, childNode)
+}
+
+// removeChild detaches the node's child for move, keeping the others in order.
+func (node *expectimaxNode) removeChild(move interface{}) {
+	childNode := node.children[move]
+	delete(node.children, move)
+	for i, orderedChild := range node.orderedChildren {
+		if orderedChild == childNode {
+			node.orderedChildren = append(node.orderedChildren[:i], node.orderedChildren[i+1:]...)
+			break
+		}
+	}
+	childNode.parent = nil
 }
 
 func (node *expectimaxNode) addDescendents(descendentCount int) {
@@ -222,6 +367,184 @@ func (node *expectimaxNode) print(key string, depth int, likelihood float64) {
 	}
 }
 
+// nodeSnapshot is a copy of the parts of a node that are safe to hand out of the main loop.
+type nodeSnapshot struct {
+	depth     int
+	move      interface{}
+	value     float64
+	heuristic float64
+	status    explorationStatus
+	userData  interface{}
+}
+
+func (node *expectimaxNode) appendSnapshot(snapshot []nodeSnapshot, depth int, move interface{}) []nodeSnapshot {
+	if !node.incrementReference() {
+		return snapshot
+	}
+	defer node.decrementReference()
+
+	snapshot = append(snapshot, nodeSnapshot{depth, move, node.value, node.heuristic, node.explorationStatus, node.userData})
+	for childMove, childNode := range node.children {
+		snapshot = childNode.appendSnapshot(snapshot, depth+1, childMove)
+	}
+
+	return snapshot
+}
+
+// copyTree returns a copy of node and its descendents attached to parent. Nodes
+// in the middle of being explored are copied as unexplored leaves, so the copy
+// can explore them again itself.
+func (node *expectimaxNode) copyTree(parent *expectimaxNode) *expectimaxNode {
+	if !node.incrementReference() {
+		return nil
+	}
+	defer node.decrementReference()
+
+	copiedNode := getNewNode()
+	copiedNode.parent = parent
+	if node.game != nil {
+		copiedNode.game = node.game.Clone().(Game)
+	}
+	copiedNode.lastMove = node.lastMove
+	copiedNode.possibleMoves = node.possibleMoves
+	copiedNode.hash = node.hash
+	copiedNode.hashed = node.hashed
+	copiedNode.heuristic = node.heuristic
+	copiedNode.replayDepth = node.replayDepth
+	copiedNode.userData = node.userData
+	copiedNode.proof = node.proof
+	copiedNode.skipReason = node.skipReason
+	for move, weight := range node.exploreWeights {
+		copiedNode.setExploreWeight(move, weight)
+	}
+
+	switch node.explorationStatus {
+	case Unexplored, Archived:
+		copiedNode.explorationStatus = node.explorationStatus
+		copiedNode.simultaneousMoves = node.simultaneousMoves
+		copiedNode.nodeType = node.nodeType
+		copiedNode.value = node.value
+		copiedNode.minValue = node.minValue
+		copiedNode.maxValue = node.maxValue
+		copiedNode.valueSpread = node.valueSpread
+		copiedNode.perspective = node.perspective
+		copiedNode.descendentCount = node.descendentCount
+		copiedNode.averageDepth = node.averageDepth
+		copiedNode.maxDepth = node.maxDepth
+		copiedNode.pendingMoves = node.pendingMoves
+		copiedNode.takenMoves = node.takenMoves
+		copiedNode.visitCount = node.visitCount
+		copiedNode.prior = node.prior
+		for move, likelihood := range node.childLikelihood {
+			copiedNode.childLikelihood[move] = likelihood
+		}
+		for move, exploreProbability := range node.childExploreProbability {
+			copiedNode.childExploreProbability[move] = exploreProbability
+		}
+		for _, childNode := range node.orderedChildren {
+			if copiedChildNode := childNode.copyTree(copiedNode); copiedChildNode != nil {
+				copiedNode.addChild(copiedChildNode)
+			}
+		}
+	default:
+		copiedNode.value = node.heuristic
+		copiedNode.minValue = node.heuristic
+		copiedNode.maxValue = node.heuristic
+	}
+
+	copiedNode.updateMostLikelyUnexploredDescendent(false, false)
+
+	return copiedNode
+}
+
+// exportTree copies node and its descendents down to maxDepth below it, or all
+// of them if maxDepth is negative.
+func (node *expectimaxNode) exportTree(move interface{}, likelihood float64, maxDepth int, budgetSpent bool, proven bool) *TreeNode {
+	if !node.incrementReference() {
+		return nil
+	}
+	defer node.decrementReference()
+
+	treeNode := &TreeNode{Move: move, Value: node.value, Heuristic: node.heuristic, Likelihood: likelihood, UserData: node.userData, SkipReason: node.reportedSkipReason(budgetSpent, proven)}
+	if maxDepth != 0 {
+		proven = proven || node.proof != UnknownOutcome
+		for childMove, childNode := range node.children {
+			if childTreeNode := childNode.exportTree(childMove, node.childLikelihood[childMove], maxDepth-1, budgetSpent, proven); childTreeNode != nil {
+				treeNode.Children = append(treeNode.Children, childTreeNode)
+			}
+		}
+		for _, pendingMove := range node.pendingMoves {
+			treeNode.Children = append(treeNode.Children, &TreeNode{Move: pendingMove, SkipReason: SkippedForWidening})
+		}
+	}
+
+	return treeNode
+}
+
+// reportedSkipReason is why the search isn't exploring the node, given whether
+// the node budget is spent and whether an ancestor has been proven. A node next
+// in line when the budget ran out is just waiting its turn once the budget frees
+// up, as it does when the root moves on.
+func (node *expectimaxNode) reportedSkipReason(budgetSpent bool, proven bool) SkipReason {
+	switch {
+	case node.skipReason == SkippedForBudget && !budgetSpent:
+		return NotSkipped
+	case node.skipReason == NotSkipped && node.explorationStatus == Unexplored && proven:
+		return SkippedAsProven
+	}
+	return node.skipReason
+}
+
+// appendLeafValues appends the values of the node's subtree's leaves to values.
+func (node *expectimaxNode) appendLeafValues(values []float64) []float64 {
+	if !node.incrementReference() {
+		return values
+	}
+	defer node.decrementReference()
+
+	if len(node.children) == 0 {
+		return append(values, node.value)
+	}
+	for _, childNode := range node.children {
+		values = childNode.appendLeafValues(values)
+	}
+
+	return values
+}
+
+// countExplorationStatus adds this node and its descendents to counts by status.
+func (node *expectimaxNode) countExplorationStatus(counts map[string]int) {
+	if !node.incrementReference() {
+		return
+	}
+	defer node.decrementReference()
+
+	counts[node.explorationStatus.String()]++
+	for _, childNode := range node.children {
+		childNode.countExplorationStatus(counts)
+	}
+}
+
+// getMostLikelyLine follows the most likely child from this node until it reaches a leaf.
+func (node *expectimaxNode) getMostLikelyLine() []interface{} {
+	line := []interface{}{}
+	for len(node.children) > 0 {
+		var mostLikelyMove interface{}
+		mostLikelyLikelihood := -1.0
+		for childMove := range node.children {
+			if node.childLikelihood[childMove] > mostLikelyLikelihood {
+				mostLikelyMove = childMove
+				mostLikelyLikelihood = node.childLikelihood[childMove]
+			}
+		}
+
+		line = append(line, mostLikelyMove)
+		node = node.children[mostLikelyMove]
+	}
+
+	return line
+}
+
 func (node *expectimaxNode) updateAverageDepth() {
 	if !node.incrementReference() {
 		return
@@ -245,12 +568,35 @@ func (node *expectimaxNode) updateAverageDepth() {
 	}
 }
 
-func (node *expectimaxNode) updateMostLikelyUnexploredDescendent(recursive bool, printDebug bool) {
+// updateMaxDepth deepens the longest line recorded below the node and its
+// ancestors to take in the node's children.
+func (node *expectimaxNode) updateMaxDepth() {
+	depth := 0
+	if len(node.children) > 0 {
+		depth = 1
+	}
+
+	for ancestor := node; ancestor != nil && ancestor.maxDepth < depth; ancestor = ancestor.parent {
+		ancestor.maxDepth = depth
+		depth++
+	}
+}
+
+// nodeDescendentUpdates counts the times any node's most likely unexplored
+// descendent has been recalculated.
+var nodeDescendentUpdates int64
+
+// updateMostLikelyUnexploredDescendent recalculates the node's most likely
+// unexplored descendent from its children, and its ancestors' too if recursive,
+// returning whether the node's changed.
+func (node *expectimaxNode) updateMostLikelyUnexploredDescendent(recursive bool, printDebug bool) bool {
 	if !node.incrementReference() {
-		return
+		return false
 	}
 	defer node.decrementReference()
 
+	atomic.AddInt64(&nodeDescendentUpdates, 1)
+
 	var mostLikelyUnexploredDescendent *expectimaxNode
 	var mostLikelyUnexploredDescendentLikelihood float64
 
@@ -265,12 +611,17 @@ func (node *expectimaxNode) updateMostLikelyUnexploredDescendent(recursive bool,
 		mostLikelyUnexploredDescendent = nil
 		mostLikelyUnexploredDescendentLikelihood = 0.0
 
-		for childMove, child := range node.children {
+		// Children are taken in order so ties go to the earliest, whatever the map order
+		for _, child := range node.orderedChildren {
+			if node.proof != UnknownOutcome {
+				break // A proven subtree needs no more search
+			}
+
 			if child.mostLikelyUnexploredDescendent == nil || (child.explorationStatus != Unexplored && child.explorationStatus != Archived) {
 				continue
 			}
 
-			exploreChildLikelihood := child.mostLikelyUnexploredDescendentLikelihood * node.childExploreProbability[childMove]
+			exploreChildLikelihood := child.mostLikelyUnexploredDescendentLikelihood * node.childExploreProbability[child.lastMove]
 
 			if mostLikelyUnexploredDescendentLikelihood < exploreChildLikelihood {
 				mostLikelyUnexploredDescendent = child.mostLikelyUnexploredDescendent
@@ -294,7 +645,10 @@ func (node *expectimaxNode) updateMostLikelyUnexploredDescendent(recursive bool,
 		if recursive && parent != nil {
 			parent.updateMostLikelyUnexploredDescendent(true, printDebug)
 		}
+		return true
 	}
+
+	return false
 }
 
 func (node *expectimaxNode) setWaitingForExploration() {
@@ -310,11 +664,173 @@ func (node *expectimaxNode) setWaitingForExploration() {
 func NewBaseNode(game Game) *expectimaxNode {
 	node := getNewNode()
 	node.game = game.Clone().(Game)
+	if hashableGame, ok := node.game.(HashableGame); ok {
+		node.hash, node.hashed = hashableGame.Hash(), true
+	}
+	node.archiveIfGameOver(node.game)
 	return node
 }
 
-func (node *expectimaxNode) Explore(heuristic ExpectimaxHeuristic, calculateChildLikelihoodFunc ExpectimaxChildLikelihoodFunc) {
+// createUserData gives a new node its data from WithOnNodeCreate, if set.
+func (node *expectimaxNode) createUserData(settings *searchSettings) {
+	if settings.onNodeCreate != nil {
+		node.userData = settings.onNodeCreate()
+	}
+}
+
+// buildTree gives the node its heuristic and the children described by spec, as
+// though they'd all been explored and processed, and backs up its value from
+// them.
+func (node *expectimaxNode) buildTree(settings *searchSettings, spec *TreeSpec) {
+	node.heuristic = spec.Heuristic
+	node.value, node.minValue, node.maxValue = spec.Heuristic, spec.Heuristic, spec.Heuristic
+	if len(spec.Children) == 0 {
+		if spec.Terminal {
+			node.archive()
+		}
+		return
+	}
+
+	if settings.alternatingPerspective {
+		if multiplayerGame, ok := node.GetGame().(MultiplayerGame); ok && multiplayerGame.GetCurrentPlayer() != 0 {
+			node.perspective = -1.0
+		}
+	}
+
+	var averageDepth float64
+	for _, childSpec := range spec.Children {
+		childNode := getNewNode()
+		childNode.parent = node
+		childNode.lastMove = childSpec.Move
+		childNode.createUserData(settings)
+		node.addChild(childNode)
+		node.childLikelihood[childSpec.Move] = 0
+		node.childExploreProbability[childSpec.Move] = 0
+
+		childNode.buildTree(settings, childSpec)
+		node.descendentCount += 1 + childNode.descendentCount
+		averageDepth += childNode.averageDepth
+		if childNode.maxDepth >= node.maxDepth {
+			node.maxDepth = childNode.maxDepth + 1
+		}
+	}
+
+	node.averageDepth = 1.0 + averageDepth/float64(len(node.children))
+	node.takenMoves = len(node.children)
+	node.explorationStatus = Archived
+	node.calculateChildLikelihood(settings, false)
+}
+
+// archiveIfGameOver archives a node for a finished game so it keeps its terminal
+// value and is never handed to a worker.
+func (node *expectimaxNode) archiveIfGameOver(game Game) {
+	if game.IsGameOver() {
+		node.archive()
+	}
+}
+
+// archive marks a node that will never be explored as finished.
+func (node *expectimaxNode) archive() {
+	if node.mostLikelyUnexploredDescendent != nil && node.mostLikelyUnexploredDescendent != node {
+		node.mostLikelyUnexploredDescendent.decrementReference()
+	}
+
+	node.explorationStatus = Archived
+	node.mostLikelyUnexploredDescendent = nil
+	node.mostLikelyUnexploredDescendentLikelihood = 0.0
+}
+
+// exploredChild is a child found by findChildren, before a node is made for it.
+type exploredChild struct {
+	move          interface{}
+	heuristic     float64
+	probability   float64
+	possibleMoves *extensions.InterfaceSlice
+	game          Game // Set with game caching
+	hash          uint64
+	repeated      bool // Repeats a position further up the path
+	noOp          bool // Leaves the position as it was, kept as a leaf when every move does
+	gameOver      bool
+	outcome       Outcome // How the game ended, for a finished OutcomeGame or a repeat
+}
+
+// exploration holds what findChildren learned about a node, to be attached to it
+// by attachChildren.
+type exploration struct {
+	perspective       float64
+	simultaneousMoves [2][]interface{}
+	nodeType          NodeType
+	hashed            bool
+	children          []exploredChild
+	pendingMoves      []interface{} // Left for progressive widening, best first
+	takenMoves        int           // Moves considered, including any collapsed
+	invalidMoves      []interface{} // Moves skipped for failing IsValidMove
+	moveErrors        []string      // Why MakeMove failed for the moves skipped for it
+	prior             map[interface{}]float64
+	skipReason        SkipReason // Set when the node is to be kept as a leaf instead
+	exploreError      error      // Why the node is kept as a leaf, when that's a problem
+	missingGame       bool       // The node's game is no longer available, so it's left childless
+}
+
+// Explore finds the node's children and attaches them, all on the calling
+// goroutine.
+func (node *expectimaxNode) Explore(settings *searchSettings) {
+	node.attachExploration(settings, node.findExploration(settings))
+}
+
+// findExploration works out what exploring the node finds, without changing the
+// node, so a worker can do it while the main loop reads the tree. It returns nil
+// if the node is being deleted.
+func (node *expectimaxNode) findExploration(settings *searchSettings) *exploration {
 	if !node.incrementReference() {
+		return nil
+	}
+	defer node.decrementReference()
+
+	if settings.maxDepth > 0 && node.depth() >= settings.maxDepth {
+		return &exploration{skipReason: SkippedForDepth}
+	}
+
+	nodeGame := node.GetGame()
+
+	if nodeGame == nil {
+		return &exploration{missingGame: true}
+	}
+
+	ancestorHashes, ancestorGames := node.getAncestry(settings, nodeGame)
+
+	if settings.exploreTimeout <= 0 {
+		return findChildren(settings, nodeGame, node.possibleMoves, node.perspective, ancestorHashes, ancestorGames, node.keepsChildGames(settings))
+	}
+
+	// findChildren doesn't touch the node, so it can be left running if it hangs.
+	// It runs with its own copy of the settings, as SetHeuristic and
+	// SetChildLikelihood don't wait for an explore that's been left running, and
+	// the copy's table generation keeps its values out of a table cleared since.
+	exploreSettings := *settings
+	found := make(chan *exploration, 1)
+	go func(possibleMoves *extensions.InterfaceSlice, perspective float64, keepGames bool) {
+		found <- findChildren(&exploreSettings, nodeGame, possibleMoves, perspective, ancestorHashes, ancestorGames, keepGames)
+	}(node.possibleMoves, node.perspective, node.keepsChildGames(settings))
+
+	timer := time.NewTimer(settings.exploreTimeout)
+	defer timer.Stop()
+
+	select {
+	case exploration := <-found:
+		return exploration
+	case <-timer.C:
+		return &exploration{
+			skipReason:   SkippedForTimeout,
+			exploreError: fmt.Errorf("expectimax: exploring the position after move %v took longer than %v, keeping its heuristic value", node.lastMove, settings.exploreTimeout),
+		}
+	}
+}
+
+// attachExploration gives the node what findExploration found. It's done on the
+// main loop for the nodes the workers explore.
+func (node *expectimaxNode) attachExploration(settings *searchSettings, exploration *exploration) {
+	if exploration == nil || !node.incrementReference() {
 		return
 	}
 	defer node.decrementReference()
@@ -324,36 +840,349 @@ func (node *expectimaxNode) Explore(heuristic ExpectimaxHeuristic, calculateChil
 	}
 
 	node.explorationStatus = Exploring
+	node.skipReason = NotSkipped
 	node.mostLikelyUnexploredDescendent = nil
 	node.mostLikelyUnexploredDescendentLikelihood = 0.0
-	nodeGame := node.GetGame()
 
-	if nodeGame == nil {
+	if exploration.missingGame {
+		return
+	}
+	if exploration.skipReason != NotSkipped {
+		// Keep the node as a leaf valued by its own heuristic
+		node.exploreError = exploration.exploreError
+		node.explorationStatus = Explored
+		node.skipReason = exploration.skipReason
 		return
 	}
 
-	for _, move := range *nodeGame.GetPossibleMoves() {
+	node.attachChildren(settings, exploration)
+}
+
+// keepsChildGames reports whether the node's children should keep their games,
+// with WithGameCaching or once they'd be WithMaterializeInterval moves from the
+// nearest game.
+func (node *expectimaxNode) keepsChildGames(settings *searchSettings) bool {
+	return settings.cacheGames || (settings.materializeInterval > 0 && node.replayDepth+1 >= settings.materializeInterval)
+}
+
+// findChildren works out the children of nodeGame's position, keeping their games
+// if keepGames is set. It only reads its arguments, so that it can be abandoned
+// part way through.
+func findChildren(settings *searchSettings, nodeGame Game, possibleMoves *extensions.InterfaceSlice, perspective float64, ancestorHashes map[uint64]bool, ancestorGames []Game, keepGames bool) *exploration {
+	exploration := &exploration{perspective: perspective, nodeType: DecisionNode, hashed: ancestorHashes != nil}
+
+	if settings.alternatingPerspective {
+		if multiplayerGame, ok := nodeGame.(MultiplayerGame); ok && multiplayerGame.GetCurrentPlayer() != 0 {
+			exploration.perspective = -1.0
+		}
+	}
+
+	simultaneousGame, simultaneous := nodeGame.(SimultaneousGame)
+	if simultaneous {
+		exploration.simultaneousMoves[0], exploration.simultaneousMoves[1], possibleMoves = getJointMoves(simultaneousGame)
+	} else if possibleMoves == nil {
+		possibleMoves = nodeGame.GetPossibleMoves()
+	}
+
+	// Chance nodes take their child likelihoods from the game's rules
+	chanceGame, chance := nodeGame.(ChanceGame)
+	if chance && chanceGame.GetNodeType() == ChanceNode {
+		exploration.nodeType = ChanceNode
+	}
+
+	// Past the branching cap, decision nodes keep only their best moves
+	if maxBranching := settings.maxBranching; maxBranching > 0 && !simultaneous && exploration.nodeType == DecisionNode && len(*possibleMoves) > maxBranching {
+		orderedMoves := orderMoves(nodeGame, possibleMoves)[:maxBranching]
+		possibleMoves = &orderedMoves
+	}
+
+	// With progressive widening, decision nodes start with only their best few moves
+	if widening := settings.widening; widening.initialChildren > 0 && !simultaneous && exploration.nodeType == DecisionNode && len(*possibleMoves) > widening.initialChildren {
+		orderedMoves := orderMoves(nodeGame, possibleMoves)
+		initialMoves := orderedMoves[:widening.initialChildren]
+		exploration.pendingMoves = orderedMoves[widening.initialChildren:]
+		possibleMoves = &initialMoves
+	}
+	exploration.takenMoves = len(*possibleMoves)
+
+	if settings.prior != nil && !simultaneous && exploration.nodeType == DecisionNode {
+		exploration.prior = settings.prior(nodeGame)
+	}
+
+	// Collapse no-op moves and duplicate siblings when the game can be hashed. The
+	// payoff matrix of a simultaneous node needs every joint move, so never there.
+	// Children repeating a position further up the path are cut off as draws.
+	// An EqualGame confirms that states with the same hash really are the same.
+	// A chance outcome that changes nothing just comes round again, so the other
+	// outcomes' probabilities are scaled up to cover it; if every move is a no-op,
+	// the first is kept as a leaf so the position isn't taken to have none.
+	var parentHash uint64
+	var noOp *exploredChild
+	var noOpGame Game
+	var siblings map[uint64][]int
+	var siblingGames []Game
+	equalGame, equal := nodeGame.(EqualGame)
+	if exploration.hashed {
+		parentHash = nodeGame.(HashableGame).Hash()
+		if !simultaneous {
+			siblings = map[uint64][]int{}
+		}
+	}
+
+	for _, move := range *possibleMoves {
+		// Joint moves of a simultaneous node aren't moves the game can check
+		if settings.validateMoves && !simultaneous && !nodeGame.IsValidMove(move) {
+			exploration.invalidMoves = append(exploration.invalidMoves, move)
+			continue
+		}
+
 		childGame := nodeGame.Clone().(Game)
-		childGame.MakeMove(move)
+		if err := childGame.MakeMove(move); err != nil {
+			exploration.moveErrors = append(exploration.moveErrors, fmt.Sprintf("MakeMove(%v) failed: %v", move, err))
+			continue
+		}
+
+		child := exploredChild{move: move}
+		if exploration.nodeType == ChanceNode {
+			child.probability = chanceGame.GetMoveProbability(move)
+		}
 
-		childHeuristic := heuristic(childGame)
+		if exploration.hashed {
+			child.hash = childGame.(HashableGame).Hash()
+		}
 
-		childNode := getNewNode()
-		childNode.parent = node
-		childNode.heuristic = childHeuristic
-		childNode.value = childHeuristic
-		childNode.lastMove = move
+		if siblings != nil {
+			if child.hash == parentHash && (!equal || equalGame.Equal(childGame)) {
+				if noOp == nil {
+					noOp, noOpGame = &exploredChild{move: move, hash: child.hash, noOp: true}, childGame
+				}
+				noOp.probability += child.probability
+				continue
+			}
+
+			merged := false
+			for _, sibling := range siblings[child.hash] {
+				if !equal || siblingGames[sibling].(EqualGame).Equal(childGame) {
+					exploration.children[sibling].probability += child.probability
+					merged = true
+					break
+				}
+			}
+			if merged {
+				continue
+			}
+
+			siblings[child.hash] = append(siblings[child.hash], len(exploration.children))
+			if equal {
+				siblingGames = append(siblingGames, childGame)
+			}
+		}
 
-		node.children[move] = childNode
-		node.childLikelihood[move] = 0
-		node.childExploreProbability[move] = 0
+		child.repeated = ancestorHashes[child.hash] || repeatsGame(childGame, ancestorGames)
+		if child.repeated {
+			child.heuristic = settings.drawValue
+			child.outcome = DrawOutcome
+		} else {
+			child.heuristic = settings.evaluateHashed(childGame, move, child.hash, exploration.hashed)
+			child.gameOver = childGame.IsGameOver()
+			if outcomeGame, ok := childGame.(OutcomeGame); ok && child.gameOver {
+				child.outcome = outcomeGame.GetOutcome()
+			}
+		}
+		child.heuristic = settings.valueScale.clamp(child.heuristic)
+
+		if settings.cachePossibleMoves {
+			child.possibleMoves = childGame.GetPossibleMoves()
+		}
+		if keepGames {
+			child.game = childGame
+		}
+
+		exploration.children = append(exploration.children, child)
+	}
+
+	if len(exploration.children) == 0 && noOp != nil {
+		noOp.heuristic = settings.valueScale.clamp(settings.evaluateHashed(noOpGame, noOp.move, noOp.hash, exploration.hashed))
+		if settings.cachePossibleMoves {
+			noOp.possibleMoves = noOpGame.GetPossibleMoves()
+		}
+		if keepGames {
+			noOp.game = noOpGame
+		}
+		exploration.children = append(exploration.children, *noOp)
+	}
+
+	return exploration
+}
+
+// attachChildren gives node the children found by findChildren.
+func (node *expectimaxNode) attachChildren(settings *searchSettings, exploration *exploration) {
+	node.perspective = exploration.perspective
+	node.simultaneousMoves = exploration.simultaneousMoves
+	node.nodeType = exploration.nodeType
+	node.pendingMoves = exploration.pendingMoves
+	node.prior = exploration.prior
+	node.addChildren(settings, exploration)
+
+	if totalProbability := node.childLikelihood.GetTotalValue(); node.nodeType == ChanceNode && totalProbability > 0.0 {
+		for move, probability := range node.childLikelihood {
+			node.childLikelihood[move] = probability / totalProbability
+		}
 	}
 
 	node.descendentCount = len(node.children)
 	node.averageDepth = 1.0
 	node.explorationStatus = Explored
 
-	node.calculateChildLikelihood(calculateChildLikelihoodFunc, false)
+	node.calculateChildLikelihood(settings, false)
+}
+
+// addChildren makes nodes for the children in exploration, adding them to the
+// maps the node already has.
+func (node *expectimaxNode) addChildren(settings *searchSettings, exploration *exploration) {
+	node.takenMoves += exploration.takenMoves
+	problems := exploration.moveErrors
+	if len(exploration.invalidMoves) > 0 {
+		problems = append([]string{fmt.Sprintf("moves %v aren't valid", exploration.invalidMoves)}, problems...)
+	}
+	if len(problems) > 0 {
+		node.exploreError = fmt.Errorf("expectimax: skipping moves listed by GetPossibleMoves after move %v: %s", node.lastMove, strings.Join(problems, "; "))
+	}
+	for _, child := range exploration.children {
+		childNode := getNewNode()
+		childNode.parent = node
+		childNode.heuristic = child.heuristic
+		childNode.value = child.heuristic
+		childNode.minValue = child.heuristic
+		childNode.maxValue = child.heuristic
+		childNode.lastMove = child.move
+		childNode.possibleMoves = child.possibleMoves
+		childNode.createUserData(settings)
+		childNode.game = child.game
+		if child.game == nil {
+			childNode.replayDepth = node.replayDepth + 1
+		}
+		childNode.hash, childNode.hashed = child.hash, exploration.hashed
+		childNode.proof = child.outcome
+		if child.repeated || child.noOp {
+			childNode.skipReason = SkippedAsRepeat
+		}
+		if child.repeated || child.noOp || child.gameOver {
+			childNode.archive()
+		}
+
+		node.addChild(childNode)
+		node.childLikelihood[child.move] = child.probability
+		node.childExploreProbability[child.move] = 0
+	}
+}
+
+// widen adds children for the node's pending moves while its visits allow, or
+// while it has nothing left to explore. It must be called from the main loop.
+func (node *expectimaxNode) widen(settings *searchSettings) {
+	if !node.incrementReference() {
+		return
+	}
+	defer node.decrementReference()
+
+	widening := settings.widening
+	allowedMoves := widening.initialChildren + node.visitCount/widening.visitsPerChild
+	for len(node.pendingMoves) > 0 && (node.takenMoves < allowedMoves || node.mostLikelyUnexploredDescendent == nil) {
+		nodeGame := node.GetGame()
+		if nodeGame == nil {
+			return
+		}
+
+		ancestorHashes, ancestorGames := node.getAncestry(settings, nodeGame)
+
+		move := node.pendingMoves[0]
+		node.pendingMoves = node.pendingMoves[1:]
+		exploration := findChildren(settings, nodeGame, &extensions.InterfaceSlice{move}, node.perspective, ancestorHashes, ancestorGames, node.keepsChildGames(settings))
+		node.addChildren(settings, exploration)
+
+		node.addDescendents(len(exploration.children))
+		node.calculateChildLikelihood(settings, true)
+	}
+}
+
+// orderMoves returns the moves highest priority first for a MoveOrderingGame, or
+// in the game's order otherwise.
+func orderMoves(game Game, moves *extensions.InterfaceSlice) extensions.InterfaceSlice {
+	orderedMoves := make(extensions.InterfaceSlice, len(*moves))
+	copy(orderedMoves, *moves)
+
+	if moveOrderingGame, ok := game.(MoveOrderingGame); ok {
+		priorities := make(map[interface{}]float64, len(orderedMoves))
+		for _, move := range orderedMoves {
+			priorities[move] = moveOrderingGame.GetMovePriority(move)
+		}
+		sort.SliceStable(orderedMoves, func(i, j int) bool {
+			return priorities[orderedMoves[i]] > priorities[orderedMoves[j]]
+		})
+	}
+
+	return orderedMoves
+}
+
+// getAncestry returns what findChildren needs to spot children repeating a
+// position on the path from the root: the hashes of the positions above the node
+// for a HashableGame, or with Equal cycle detection, the games along the path
+// including the node's own for an EqualGame that can't be hashed.
+func (node *expectimaxNode) getAncestry(settings *searchSettings, nodeGame Game) (map[uint64]bool, []Game) {
+	if _, ok := nodeGame.(HashableGame); ok {
+		return node.getAncestorHashes(), nil
+	}
+	if _, ok := nodeGame.(EqualGame); ok && settings.equalCycleDetection {
+		return nil, node.getAncestorGames()
+	}
+
+	return nil, nil
+}
+
+// getAncestorGames rebuilds the game at each node from the root down to this
+// node, replaying moves from the nearest node that keeps its game.
+func (node *expectimaxNode) getAncestorGames() []Game {
+	var path []*expectimaxNode
+	for ancestor := node; ancestor != nil; ancestor = ancestor.parent {
+		path = append(path, ancestor)
+	}
+
+	ancestorGames := make([]Game, 0, len(path))
+	var game Game
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].game != nil {
+			game = path[i].game.Clone().(Game)
+		} else {
+			game = game.Clone().(Game)
+			game.MakeMove(path[i].lastMove)
+		}
+		ancestorGames = append(ancestorGames, game)
+	}
+
+	return ancestorGames
+}
+
+// repeatsGame reports whether game is Equal to any of games.
+func repeatsGame(game Game, games []Game) bool {
+	for _, other := range games {
+		if other.(EqualGame).Equal(game) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getAncestorHashes returns the hashes of the positions above this node.
+func (node *expectimaxNode) getAncestorHashes() map[uint64]bool {
+	ancestorHashes := map[uint64]bool{}
+	for ancestor := node.parent; ancestor != nil; ancestor = ancestor.parent {
+		if ancestor.hashed {
+			ancestorHashes[ancestor.hash] = true
+		}
+	}
+
+	return ancestorHashes
 }
 
 func (node *expectimaxNode) getChildValue(childMove interface{}) float64 {
@@ -362,28 +1191,102 @@ func (node *expectimaxNode) getChildValue(childMove interface{}) float64 {
 		return 0.0
 	}
 
-	return childNode.value
+	return node.perspective * childNode.value
 }
 
-func (node *expectimaxNode) calculateChildLikelihood(calculateChildLikelihoodFunc ExpectimaxChildLikelihoodFunc, recursive bool) {
+func (node *expectimaxNode) calculateChildLikelihood(settings *searchSettings, recursive bool) {
 	if !node.incrementReference() {
 		return
 	}
 	defer node.decrementReference()
 
-	calculateChildLikelihoodFunc(node.GetGame, node.getChildValue, &node.childLikelihood)
+	parent := node.parent
+	if node.backup(settings) && recursive && parent != nil {
+		node.updateMostLikelyUnexploredDescendent(false, false)
+		parent.calculateChildLikelihood(settings, true)
+	} else {
+		node.updateMostLikelyUnexploredDescendent(recursive, false)
+	}
+}
+
+// nodeBackups counts the times any node's value has been recalculated from its
+// children.
+var nodeBackups int64
+
+// backup recalculates the node's likelihoods and value from its children,
+// returning whether its value or bounds changed. The caller must hold a
+// reference to the node.
+func (node *expectimaxNode) backup(settings *searchSettings) bool {
+	atomic.AddInt64(&nodeBackups, 1)
+
+	switch {
+	case node.simultaneousMoves[0] != nil:
+		node.calculateSimultaneousLikelihood(settings.simultaneousSolution)
+	case node.nodeType == ChanceNode:
+		// The game's probabilities were set when the node was explored
+	default:
+		settings.calculateChildLikelihood(node.GetGame, node.getChildValue, &node.childLikelihood)
+	}
+
+	proof := node.proof
+	if settings.proofPropagation && len(node.children) > 0 {
+		proof = node.findProof()
+	}
+
+	var totalPrior float64
+	for move := range node.childLikelihood {
+		totalPrior += node.prior[move]
+	}
+
+	var totalSpread float64
+	if settings.uncertaintyWeight > 0.0 {
+		for _, childNode := range node.orderedChildren {
+			totalSpread += childNode.valueSpread
+		}
+	}
 
 	for move, likelihood := range node.childLikelihood {
+		if totalPrior > 0.0 {
+			likelihood = (1.0-settings.priorWeight)*likelihood + settings.priorWeight*node.prior[move]/totalPrior
+		}
+		if childNode, ok := node.children[move]; ok && totalSpread > 0.0 {
+			likelihood = (1.0-settings.uncertaintyWeight)*likelihood + settings.uncertaintyWeight*childNode.valueSpread/totalSpread
+		}
 		node.childExploreProbability[move] = (0.1 / float64(len(node.childLikelihood))) + 0.9*likelihood // 10% spread for exploration regardless of likelihood
+		if weight, ok := node.exploreWeights[move]; ok {
+			node.childExploreProbability[move] *= weight
+		}
 	}
 
 	var value float64
-	if len(node.children) == 0 {
-		value = node.heuristic
-	} else {
-		for childMove, childNode := range node.children {
-			value += node.childLikelihood[childMove] * childNode.value
+	minValue, maxValue := node.heuristic, node.heuristic
+	if len(node.children) != 0 {
+		logOdds := settings.valueScale == LogOddsValues && node.nodeType == ChanceNode
+		minValue, maxValue = math.Inf(1), math.Inf(-1)
+		sum := valueSum{compensated: settings.compensatedSummation}
+		for _, childNode := range node.orderedChildren { // In order, so the rounding is the same every run
+			if logOdds {
+				sum.add(node.childLikelihood[childNode.lastMove] * logit(childNode.value))
+			} else {
+				sum.add(node.childLikelihood[childNode.lastMove] * childNode.value)
+			}
+			minValue = math.Min(minValue, childNode.minValue)
+			maxValue = math.Max(maxValue, childNode.maxValue)
+		}
+		value = sum.total
+		if logOdds {
+			value = logistic(value)
+		}
+
+		// The root's heuristic is never evaluated, and its value doesn't steer any choice
+		if settings.heuristicBlend > 0.0 && node.parent != nil {
+			weight := settings.heuristicBlend / (settings.heuristicBlend + float64(node.descendentCount))
+			value = (1.0-weight)*value + weight*node.heuristic
 		}
+
+		value = settings.valueScale.clamp(value)
+	} else {
+		value = node.heuristic
 	}
 
 	if math.IsNaN(value) {
@@ -391,32 +1294,230 @@ func (node *expectimaxNode) calculateChildLikelihood(calculateChildLikelihoodFun
 		log.Fatal("NaN value in recursiveCalculateChildLikelihood!")
 	}
 
-	parent := node.parent
-	if recursive && value != node.value && parent != nil {
-		node.value = value
-		node.updateMostLikelyUnexploredDescendent(false, false)
-		parent.calculateChildLikelihood(calculateChildLikelihoodFunc, true)
-	} else {
-		node.value = value
-		node.updateMostLikelyUnexploredDescendent(recursive, false)
+	var valueSpread float64
+	if settings.uncertaintyWeight > 0.0 {
+		valueSpread = node.findValueSpread()
 	}
+
+	changed := value != node.value || minValue != node.minValue || maxValue != node.maxValue || proof != node.proof || valueSpread != node.valueSpread
+	node.valueSpread = valueSpread
+	node.minValue = minValue
+	node.maxValue = maxValue
+	node.value = value
+	node.proof = proof
+
+	return changed
 }
 
-func (node *expectimaxNode) processExploredNode(calculateChildLikelihoodFunc ExpectimaxChildLikelihoodFunc) {
+// recomputeValues evaluates the node and its descendents again with the current
+// heuristic and backs their values up from the leaves. The root's heuristic is
+// never evaluated, and positions cut off as repeats keep their draw value. It
+// must be called from the main loop with no nodes handed to the workers.
+func (node *expectimaxNode) recomputeValues(settings *searchSettings) {
 	if !node.incrementReference() {
 		return
 	}
 	defer node.decrementReference()
 
+	for _, childNode := range node.orderedChildren {
+		childNode.recomputeValues(settings)
+	}
+
+	if node.parent != nil {
+		game := node.GetGame()
+		repeated := len(node.children) == 0 && node.explorationStatus == Archived && node.proof == DrawOutcome && !game.IsGameOver()
+		if !repeated {
+			node.heuristic = settings.valueScale.clamp(settings.evaluateHashed(game, node.lastMove, node.hash, node.hashed))
+		}
+	}
+
+	node.backup(settings)
+	node.updateMostLikelyUnexploredDescendent(false, false)
+}
+
+// findValueSpread is the standard deviation of the values of the node's
+// children, 0 without children.
+func (node *expectimaxNode) findValueSpread() float64 {
+	if len(node.orderedChildren) == 0 {
+		return 0.0
+	}
+
+	var mean float64
+	for _, childNode := range node.orderedChildren {
+		mean += childNode.value
+	}
+	mean /= float64(len(node.orderedChildren))
+
+	var variance float64
+	for _, childNode := range node.orderedChildren {
+		variance += (childNode.value - mean) * (childNode.value - mean)
+	}
+
+	return math.Sqrt(variance / float64(len(node.orderedChildren)))
+}
+
+// winningOutcome is the outcome for player 0 of the player to move winning.
+func (node *expectimaxNode) winningOutcome() Outcome {
+	if node.perspective < 0.0 {
+		return LossOutcome
+	}
+	return WinOutcome
+}
+
+// losingOutcome is the outcome for player 0 of the player to move losing.
+func (node *expectimaxNode) losingOutcome() Outcome {
+	if node.perspective < 0.0 {
+		return WinOutcome
+	}
+	return LossOutcome
+}
+
+// findProof works out what the node's children prove about it. At a decision
+// node a single winning move proves a win for the player to move, and proving
+// every move proves the best of them. A chance node is proven once all its
+// outcomes are proven the same. A winning move is made certain in the child
+// likelihoods, as it's the one that will be played.
+func (node *expectimaxNode) findProof() Outcome {
+	if node.simultaneousMoves[0] != nil {
+		return UnknownOutcome
+	}
+
+	if node.nodeType == ChanceNode {
+		proof := UnknownOutcome
+		for _, childNode := range node.children {
+			if childNode.proof == UnknownOutcome || (proof != UnknownOutcome && childNode.proof != proof) {
+				return UnknownOutcome
+			}
+			proof = childNode.proof
+		}
+		return proof
+	}
+
+	win, loss := node.winningOutcome(), node.losingOutcome()
+	proof := loss
+	for childMove, childNode := range node.children {
+		switch childNode.proof {
+		case win:
+			for move := range node.childLikelihood {
+				node.childLikelihood[move] = 0.0
+			}
+			node.childLikelihood[childMove] = 1.0
+			return win
+		case UnknownOutcome:
+			proof = UnknownOutcome
+		case DrawOutcome:
+			if proof == loss {
+				proof = DrawOutcome
+			}
+		}
+	}
+	if len(node.pendingMoves) > 0 {
+		return UnknownOutcome // Moves not yet added could still do better
+	}
+
+	return proof
+}
+
+// backupBatch defers backing up the ancestors of explored nodes until flush, so
+// an ancestor shared by several of them is recalculated once rather than once
+// for each. The same goes for their most likely unexplored descendents, which
+// are brought up to date a level at a time rather than by a walk to the root
+// for every node.
+type backupBatch struct {
+	queued map[*expectimaxNode]bool // Whether each queued node needs backing up, or only its descendent
+	levels [][]*expectimaxNode      // Queued nodes by depth below the root
+}
+
+func newBackupBatch() *backupBatch {
+	return &backupBatch{queued: map[*expectimaxNode]bool{}}
+}
+
+// markDirty queues node to be backed up when the batch is flushed.
+func (batch *backupBatch) markDirty(node *expectimaxNode) {
+	batch.queue(node, true)
+}
+
+// markStale queues node to have only its most likely unexplored descendent
+// recalculated when the batch is flushed.
+func (batch *backupBatch) markStale(node *expectimaxNode) {
+	batch.queue(node, false)
+}
+
+func (batch *backupBatch) queue(node *expectimaxNode, backup bool) {
+	if queuedBackup, ok := batch.queued[node]; ok {
+		batch.queued[node] = queuedBackup || backup
+		return
+	}
+	if !node.incrementReference() { // This will be decremented once the batch is flushed
+		return
+	}
+	batch.queued[node] = backup
+
+	depth := node.depth()
+	for len(batch.levels) <= depth {
+		batch.levels = append(batch.levels, nil)
+	}
+	batch.levels[depth] = append(batch.levels[depth], node)
+}
+
+// flush brings the queued nodes up to date deepest first, queueing the parent of
+// any whose value or most likely unexplored descendent changes, so each node is
+// recalculated once, after all its changed children.
+func (batch *backupBatch) flush(settings *searchSettings) {
+	for depth := len(batch.levels) - 1; depth >= 0; depth-- {
+		for _, node := range batch.levels[depth] {
+			parent := node.parent
+			valueChanged := batch.queued[node] && node.backup(settings)
+			descendentChanged := node.updateMostLikelyUnexploredDescendent(false, false)
+			switch {
+			case parent == nil:
+			case valueChanged:
+				batch.markDirty(parent)
+			case descendentChanged:
+				batch.markStale(parent)
+			}
+			node.decrementReference()
+		}
+	}
+
+	batch.queued = map[*expectimaxNode]bool{}
+	batch.levels = batch.levels[:0]
+}
+
+// setExploreWeight multiplies the chance of exploring beneath move by weight from
+// the next time the node's likelihoods are calculated. A weight of 1 removes it.
+func (node *expectimaxNode) setExploreWeight(move interface{}, weight float64) {
+	if weight == 1.0 {
+		delete(node.exploreWeights, move)
+		return
+	}
+
+	if node.exploreWeights == nil {
+		node.exploreWeights = map[interface{}]float64{}
+	}
+	node.exploreWeights[move] = weight
+}
+
+// processExploredNode archives the explored node and queues its parent to be
+// backed up with backups.
+func (node *expectimaxNode) processExploredNode(settings *searchSettings, backups *backupBatch) bool {
+	if !node.incrementReference() {
+		return false
+	}
+	defer node.decrementReference()
+
 	node.explorationStatus = Archived
+	node.updateMaxDepth()
 
 	parent := node.parent
 	if parent != nil {
 		if parent.incrementReference() {
 			defer parent.decrementReference()
-			parent.calculateChildLikelihood(calculateChildLikelihoodFunc, true)
-			parent.updateAverageDepth()
 			parent.addDescendents(len(node.children))
+			backups.markDirty(parent)
+			parent.updateAverageDepth()
 		}
 	}
+
+	return true
 }
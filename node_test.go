@@ -0,0 +1,586 @@
+package expectimax
+
+import (
+	"math"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestExploreCollapsesDuplicateStates(t *testing.T) {
+	t.Run("test Explore() collapses no-op and duplicate moves", func(t *testing.T) {
+		initNodeMemoryPool()
+
+		sibling := &testState{value: 1.0, hash: 2}
+		root := &testState{hash: 1}
+		root.children = []*testState{sibling, root, sibling, {value: 2.0, hash: 3}}
+
+		node := NewBaseNode(&hashedTestGame{newTestGame(root)})
+		node.Explore(&searchSettings{heuristic: testHeuristic, calculateChildLikelihood: uniformChildLikelihood, heuristicCalls: new(int64)})
+
+		if len(node.children) != 2 {
+			t.Errorf("Explore() created %d children, expected 2.", len(node.children))
+		}
+
+		if _, ok := node.children[1]; ok {
+			t.Error("Explore() created a child for a no-op move.")
+		}
+
+		if node.childLikelihood[0] != 0.5 || node.childLikelihood[3] != 0.5 {
+			t.Errorf("Explore() failed to redistribute likelihood, got %v.", node.childLikelihood)
+		}
+	})
+
+	t.Run("test a state with only no-op moves keeps one as a leaf", func(t *testing.T) {
+		initNodeMemoryPool()
+
+		root := &testState{value: 4.0, hash: 1}
+		root.children = []*testState{root, root}
+
+		node := NewBaseNode(&hashedTestGame{newTestGame(root)})
+		node.Explore(&searchSettings{heuristic: testHeuristic, calculateChildLikelihood: uniformChildLikelihood, heuristicCalls: new(int64)})
+
+		childNode, ok := node.children[0]
+		if len(node.children) != 1 || !ok {
+			t.Fatalf("Explore() created children %v, expected just the first no-op move.", node.orderedChildren)
+		}
+		if childNode.explorationStatus != Archived || childNode.heuristic != 4.0 {
+			t.Errorf("No-op child has status %d and heuristic %g, expected an archived leaf worth its parent's heuristic 4.", childNode.explorationStatus, childNode.heuristic)
+		}
+		if node.value != 4.0 {
+			t.Errorf("State with only no-op moves was valued %g, expected 4.", node.value)
+		}
+	})
+}
+
+func TestWarmPool(t *testing.T) {
+	t.Run("test WarmPool() gets count nodes from the allocator and puts them back", func(t *testing.T) {
+		allocator := &countingAllocator{outstanding: map[*Node]bool{}}
+		SetAllocator(allocator)
+		defer SetAllocator(nil)
+
+		expectimax := NewExpectimax(newTestGame(leaf(0.0)), testHeuristic, uniformChildLikelihood, 1000)
+		allocator.mutex.Lock()
+		gets, puts := allocator.gets, allocator.puts
+		allocator.mutex.Unlock()
+
+		expectimax.WarmPool(100)
+
+		allocator.mutex.Lock()
+		defer allocator.mutex.Unlock()
+		if allocator.gets-gets != 100 || allocator.puts-puts != 100 {
+			t.Errorf("WarmPool(100) got %d nodes and put back %d, expected 100 of each.", allocator.gets-gets, allocator.puts-puts)
+		}
+	})
+}
+
+func benchmarkPossibleMoveCaching(b *testing.B, cachePossibleMoves bool) {
+	root := uniformTree(4, 3)
+	possibleMoveCalls := 0
+	exploredNodes := 0
+
+	for i := 0; i < b.N; i++ {
+		game := &countingTestGame{newTestGame(root), &possibleMoveCalls}
+		expectimax := NewExpectimax(game, testHeuristic, uniformChildLikelihood, 1000, WithPossibleMoveCaching(cachePossibleMoves))
+		exploreAll(expectimax)
+		exploredNodes += expectimax.rootNode.descendentCount + 1
+	}
+
+	b.ReportMetric(float64(possibleMoveCalls)/float64(exploredNodes), "calls/node")
+}
+
+func BenchmarkExploreWithoutPossibleMoveCaching(b *testing.B) {
+	benchmarkPossibleMoveCaching(b, false)
+}
+
+func BenchmarkExploreWithPossibleMoveCaching(b *testing.B) {
+	benchmarkPossibleMoveCaching(b, true)
+}
+
+func TestGameCaching(t *testing.T) {
+	t.Run("test a cached child game becomes the root's game without a replay", func(t *testing.T) {
+		root := uniformTree(2, 3)
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000, WithGameCaching(true))
+		exploreAll(expectimax)
+
+		childGame := expectimax.rootNode.children[1].children[0].game
+		if childGame == nil {
+			t.Fatalf("Explore() didn't cache the child's game.")
+		}
+		if err := expectimax.AdvanceToState([]interface{}{1, 0}); err != nil {
+			t.Fatalf("AdvanceToState() failed: %v", err)
+		}
+		if expectimax.rootNode.game != childGame {
+			t.Errorf("The new root's game was rebuilt instead of reusing the cached one.")
+		}
+		if state := expectimax.rootNode.game.(testStateGame).state(); state != root.children[1].children[0] {
+			t.Errorf("The new root's game is at %v, expected the state after moves 1 and 0.", state)
+		}
+	})
+}
+
+func benchmarkGameCaching(b *testing.B, cacheGames bool) {
+	root := uniformTree(2, 10)
+	path := []interface{}{0, 1, 0, 1, 0, 1, 0, 1}
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 10000, WithGameCaching(cacheGames))
+		exploreAll(expectimax)
+		b.StartTimer()
+
+		if err := expectimax.AdvanceToState(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDescendWithoutGameCaching(b *testing.B) {
+	benchmarkGameCaching(b, false)
+}
+
+func BenchmarkDescendWithGameCaching(b *testing.B) {
+	benchmarkGameCaching(b, true)
+}
+
+func TestMaterializeInterval(t *testing.T) {
+	t.Run("test every third node along a line keeps its game", func(t *testing.T) {
+		root := uniformTree(1, 10)
+		expectimax := NewExpectimax(newTestGame(root), testHeuristic, uniformChildLikelihood, 1000, WithMaterializeInterval(3))
+		exploreAll(expectimax)
+
+		node, state := expectimax.rootNode, root
+		for depth := 1; depth <= 10; depth++ {
+			node, state = node.children[0], state.children[0]
+			if kept := node.game != nil; kept != (depth%3 == 0) {
+				t.Fatalf("Node at depth %d kept its game: %v, expected only every third node to.", depth, kept)
+			}
+			if gameState := node.GetGame().(testStateGame).state(); gameState != state {
+				t.Fatalf("GetGame() at depth %d gave the wrong state.", depth)
+			}
+		}
+	})
+}
+
+func benchmarkMaterializeInterval(b *testing.B, interval int) {
+	expectimax := NewExpectimax(newTestGame(uniformTree(1, 255)), testHeuristic, uniformChildLikelihood, 1000, WithMaterializeInterval(interval))
+	exploreAll(expectimax)
+
+	node := expectimax.rootNode
+	for len(node.children) > 0 {
+		node = node.children[0]
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node.GetGame()
+	}
+}
+
+func BenchmarkGetGameWithoutMaterializing(b *testing.B) {
+	benchmarkMaterializeInterval(b, 0)
+}
+
+func BenchmarkGetGameMaterializeInterval16(b *testing.B) {
+	benchmarkMaterializeInterval(b, 16)
+}
+
+func BenchmarkGetGameMaterializeInterval4(b *testing.B) {
+	benchmarkMaterializeInterval(b, 4)
+}
+
+func TestPooledNodesReuseMaps(t *testing.T) {
+	t.Run("test a reset node keeps its maps for the next Explore", func(t *testing.T) {
+		initNodeMemoryPool()
+		settings := &searchSettings{heuristic: testHeuristic, calculateChildLikelihood: uniformChildLikelihood, heuristicCalls: new(int64)}
+		game := newTestGame(uniformTree(3, 2))
+
+		node := NewBaseNode(game)
+		node.Explore(settings)
+		children := reflect.ValueOf(node.children).Pointer()
+		childLikelihood := reflect.ValueOf(node.childLikelihood).Pointer()
+		childExploreProbability := reflect.ValueOf(node.childExploreProbability).Pointer()
+
+		node.reset()
+		node.game = game
+		node.Explore(settings)
+
+		if len(node.children) != 3 {
+			t.Fatalf("Explore() after reset() created %d children, expected 3.", len(node.children))
+		}
+		if reflect.ValueOf(node.children).Pointer() != children ||
+			reflect.ValueOf(node.childLikelihood).Pointer() != childLikelihood ||
+			reflect.ValueOf(node.childExploreProbability).Pointer() != childExploreProbability {
+			t.Errorf("Explore() after reset() used new maps instead of the node's own.")
+		}
+	})
+}
+
+func BenchmarkExplorePooledNodes(b *testing.B) {
+	initNodeMemoryPool()
+	settings := &searchSettings{heuristic: testHeuristic, calculateChildLikelihood: uniformChildLikelihood, heuristicCalls: new(int64)}
+	game := newTestGame(uniformTree(8, 2))
+
+	explore := func() {
+		node := NewBaseNode(game)
+		node.Explore(settings)
+		node.deleteTree(nil)
+	}
+	for i := 0; i < 100; i++ {
+		explore()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		explore()
+	}
+}
+
+// exploreBatches explores size nodes at a time the way the workers would,
+// backing each batch up together as the main loop does.
+func exploreBatches(expectimax *Expectimax, size int) {
+	for expectimax.rootNode.mostLikelyUnexploredDescendent != nil {
+		var nodes []*expectimaxNode
+		for len(nodes) < size && expectimax.rootNode.mostLikelyUnexploredDescendent != nil {
+			node := expectimax.rootNode.mostLikelyUnexploredDescendent
+			node.incrementReference()
+			node.setWaitingForExploration()
+			node.Explore(expectimax.settings)
+			nodes = append(nodes, node)
+		}
+
+		expectimax.processExploredNodes(nodes)
+		for _, node := range nodes {
+			node.decrementReference()
+		}
+	}
+}
+
+func TestBackupBatching(t *testing.T) {
+	t.Run("test batched backups give the same values as single ones", func(t *testing.T) {
+		root := branch(0.0, uniformTree(3, 3), branch(1.0, leaf(2.0), uniformTree(2, 2)), leaf(-1.0))
+
+		single := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(single)
+		batched := NewExpectimax(newTestGame(root), testHeuristic, maxChildLikelihood, 1000)
+		exploreBatches(batched, 8)
+
+		if batched.rootNode.descendentCount != single.rootNode.descendentCount {
+			t.Fatalf("Batched search has %d nodes, expected %d.", batched.rootNode.descendentCount, single.rootNode.descendentCount)
+		}
+		if batched.rootNode.value != single.rootNode.value {
+			t.Fatalf("Batched root value was %g, expected %g.", batched.rootNode.value, single.rootNode.value)
+		}
+		for move, childNode := range single.rootNode.children {
+			if value := batched.rootNode.children[move].value; value != childNode.value {
+				t.Fatalf("Batched value of move %v was %g, expected %g.", move, value, childNode.value)
+			}
+		}
+	})
+
+	t.Run("test a shared ancestor is backed up once per batch", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(4, 2)), testHeuristic, uniformChildLikelihood, 1000)
+		exploreSteps(expectimax, 1)
+
+		var nodes []*expectimaxNode
+		for _, childNode := range expectimax.rootNode.children {
+			childNode.incrementReference()
+			childNode.setWaitingForExploration()
+			childNode.Explore(expectimax.settings)
+			nodes = append(nodes, childNode)
+		}
+
+		backups := atomic.LoadInt64(&nodeBackups)
+		expectimax.processExploredNodes(nodes)
+		if count := atomic.LoadInt64(&nodeBackups) - backups; count != 1 {
+			t.Fatalf("Backing up the batch made %d backups, expected 1 for the root.", count)
+		}
+		for _, node := range nodes {
+			node.decrementReference()
+		}
+	})
+}
+
+func benchmarkBackups(b *testing.B, batchSize int) {
+	initNodeMemoryPool()
+	game := newTestGame(uniformTree(6, 3))
+	backups := atomic.LoadInt64(&nodeBackups)
+
+	for i := 0; i < b.N; i++ {
+		expectimax := NewExpectimax(game, testHeuristic, uniformChildLikelihood, 1000)
+		exploreBatches(expectimax, batchSize)
+		expectimax.rootNode.deleteTree(nil)
+	}
+
+	b.ReportMetric(float64(atomic.LoadInt64(&nodeBackups)-backups)/float64(b.N), "backups/op")
+}
+
+func BenchmarkUnbatchedBackups(b *testing.B) {
+	benchmarkBackups(b, 1)
+}
+
+func BenchmarkBatchedBackups(b *testing.B) {
+	benchmarkBackups(b, 10)
+}
+
+// benchmarkDescendentUpdates explores a deep, narrow tree, where every explored
+// node has a long path back to the root to bring up to date.
+func benchmarkDescendentUpdates(b *testing.B, batchSize int) {
+	initNodeMemoryPool()
+	game := newTestGame(uniformTree(2, 12))
+	updates := atomic.LoadInt64(&nodeDescendentUpdates)
+
+	for i := 0; i < b.N; i++ {
+		expectimax := NewExpectimax(game, testHeuristic, uniformChildLikelihood, 1000)
+		exploreBatches(expectimax, batchSize)
+		expectimax.rootNode.deleteTree(nil)
+	}
+
+	b.ReportMetric(float64(atomic.LoadInt64(&nodeDescendentUpdates)-updates)/float64(b.N), "updates/op")
+}
+
+func BenchmarkUnbatchedDescendentUpdates(b *testing.B) {
+	benchmarkDescendentUpdates(b, 1)
+}
+
+func BenchmarkBatchedDescendentUpdates(b *testing.B) {
+	benchmarkDescendentUpdates(b, 10)
+}
+
+func TestCompensatedSummation(t *testing.T) {
+	t.Run("test a wide chance node backs up to its exact expectation", func(t *testing.T) {
+		initNodeMemoryPool()
+
+		// Half the outcomes are worth 0.1 and half 1000.3, so the expectation is 500.2
+		outcomes := 10000
+		node := getNewNode()
+		node.nodeType = ChanceNode
+		node.explorationStatus = Archived
+		for move := 0; move < outcomes; move++ {
+			childNode := getNewNode()
+			childNode.parent = node
+			childNode.value = 0.1
+			if move%2 == 1 {
+				childNode.value = 1000.3
+			}
+			childNode.minValue, childNode.maxValue = childNode.value, childNode.value
+			childNode.lastMove = move
+			node.addChild(childNode)
+			node.childLikelihood[move] = 1.0 / float64(outcomes)
+		}
+
+		node.calculateChildLikelihood(&searchSettings{compensatedSummation: true}, false)
+		if math.Abs(node.value-500.2) > 1e-12 {
+			t.Errorf("Chance node backed up to %.17g, expected 500.2 to within 1e-12.", node.value)
+		}
+	})
+}
+
+func TestSimultaneousMoves(t *testing.T) {
+	payoff := [][]float64{{3.0, -1.0}, {-2.0, 1.0}}
+
+	t.Run("test a simultaneous node takes the equilibrium value", func(t *testing.T) {
+		initNodeMemoryPool()
+
+		node := NewBaseNode(&matrixGame{payoff: payoff})
+		node.Explore(&searchSettings{heuristic: matrixHeuristic, simultaneousSolution: SimultaneousEquilibrium, heuristicCalls: new(int64)})
+
+		if len(node.children) != 4 {
+			t.Errorf("Explore() created %d children, expected 4 joint moves.", len(node.children))
+		}
+		if math.Abs(node.value-1.0/7.0) > 1e-9 {
+			t.Errorf("Simultaneous node value was %g, expected 1/7.", node.value)
+		}
+	})
+
+	t.Run("test a simultaneous node takes the max-min value", func(t *testing.T) {
+		initNodeMemoryPool()
+
+		node := NewBaseNode(&matrixGame{payoff: payoff})
+		node.Explore(&searchSettings{heuristic: matrixHeuristic, simultaneousSolution: SimultaneousMaxMin, heuristicCalls: new(int64)})
+
+		if node.value != -1.0 {
+			t.Errorf("Simultaneous node value was %g, expected -1.", node.value)
+		}
+	})
+
+	t.Run("test larger matrix games approach the equilibrium", func(t *testing.T) {
+		rockPaperScissors := [][]float64{{0.0, -1.0, 1.0}, {1.0, 0.0, -1.0}, {-1.0, 1.0, 0.0}}
+		rowStrategy, columnStrategy := solveMatrixGame(rockPaperScissors, SimultaneousEquilibrium)
+
+		for i := range rowStrategy {
+			if math.Abs(rowStrategy[i]-1.0/3.0) > 0.02 || math.Abs(columnStrategy[i]-1.0/3.0) > 0.02 {
+				t.Errorf("Rock-paper-scissors strategies were %v and %v, expected thirds.", rowStrategy, columnStrategy)
+			}
+		}
+	})
+}
+
+func TestHeuristicBlend(t *testing.T) {
+	t.Run("test blended values lean on the heuristic until the subtree grows", func(t *testing.T) {
+		// Each node's heuristic is its remaining depth, so backups head towards 0
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 6)), testHeuristic, uniformChildLikelihood, 1000, WithHeuristicBlend(2.0))
+		expectimax.ensureExplored(expectimax.rootNode)
+		childNode := expectimax.rootNode.children[0]
+		expectimax.ensureExplored(childNode)
+
+		if childNode.value != 4.5 {
+			t.Errorf("Fresh subtree has value %g, expected an even blend of its heuristic 5 and backup 4.", childNode.value)
+		}
+
+		exploreAll(expectimax)
+
+		// A full subtree of depth d has 2^(d+1)-2 descendents, each blended in turn
+		expected := 0.0
+		for depth := 1; depth <= 5; depth++ {
+			weight := 2.0 / (2.0 + float64(int(1)<<uint(depth+1)-2))
+			expected = (1.0-weight)*expected + weight*float64(depth)
+		}
+		if math.Abs(childNode.value-expected) > 1e-9 {
+			t.Errorf("Grown subtree has value %g, expected %g.", childNode.value, expected)
+		}
+		if childNode.value >= 4.0 {
+			t.Errorf("Grown subtree has value %g, expected it to move towards its backup of 0.", childNode.value)
+		}
+	})
+
+	t.Run("test values are pure backups by default", func(t *testing.T) {
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 6)), testHeuristic, uniformChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		if value := expectimax.rootNode.children[0].value; value != 0.0 {
+			t.Errorf("Subtree has value %g, expected its backup of 0.", value)
+		}
+	})
+}
+
+func TestProbabilityValues(t *testing.T) {
+	t.Run("test probability backups stay within [0, 1]", func(t *testing.T) {
+		root := branch(0.5,
+			&testState{chance: true, children: []*testState{{value: 1.2, probability: 0.7}, {value: 0.9999999999999999, probability: 0.3}}},
+			branch(0.5, leaf(-0.1), leaf(0.4)),
+		)
+
+		expectimax := NewExpectimax(&chanceTestGame{newTestGame(root)}, testHeuristic, uniformChildLikelihood, 1000, WithValueScale(ProbabilityValues))
+		exploreAll(expectimax)
+
+		expectimax.Walk(func(depth int, move interface{}, value, heuristic float64, status string) bool {
+			if value < 0.0 || value > 1.0 || heuristic < 0.0 || heuristic > 1.0 {
+				t.Errorf("Node at depth %d after move %v has value %g and heuristic %g, expected both within [0, 1].", depth, move, value, heuristic)
+			}
+			return true
+		})
+		if value := expectimax.rootNode.children[1].value; math.Abs(value-0.2) > 1e-9 {
+			t.Errorf("Decision node value was %g, expected the clamped 0 and 0.4 to average 0.2.", value)
+		}
+	})
+
+	t.Run("test log-odds averaging at chance nodes", func(t *testing.T) {
+		root := branch(0.5,
+			&testState{chance: true, children: []*testState{{value: 0.5, probability: 0.5}, {value: 0.9, probability: 0.5}}},
+		)
+
+		expectimax := NewExpectimax(&chanceTestGame{newTestGame(root)}, testHeuristic, uniformChildLikelihood, 1000, WithValueScale(LogOddsValues))
+		exploreAll(expectimax)
+
+		// Half of log(9) is log(3), i.e. odds of 3:1
+		if value := expectimax.rootNode.children[0].value; math.Abs(value-0.75) > 1e-9 {
+			t.Errorf("Chance node value was %g, expected 0.75.", value)
+		}
+	})
+}
+
+func TestExploreConfirmsHashesWithEqual(t *testing.T) {
+	t.Run("test colliding states aren't merged", func(t *testing.T) {
+		initNodeMemoryPool()
+
+		// Every state shares a hash, but only repeated moves to sibling are the same
+		sibling := &testState{value: 1.0, hash: 1}
+		root := &testState{hash: 1}
+		root.children = []*testState{sibling, root, sibling, {value: 2.0, hash: 1}}
+
+		node := NewBaseNode(&equalTestGame{&hashedTestGame{newTestGame(root)}})
+		node.Explore(&searchSettings{heuristic: testHeuristic, calculateChildLikelihood: uniformChildLikelihood, heuristicCalls: new(int64)})
+
+		if len(node.children) != 2 {
+			t.Fatalf("Explore() created %d children, expected 2.", len(node.children))
+		}
+		if _, ok := node.children[3]; !ok {
+			t.Error("Explore() merged a distinct state that shares its sibling's hash.")
+		}
+		if _, ok := node.children[1]; ok {
+			t.Error("Explore() created a child for a no-op move.")
+		}
+	})
+}
+
+func TestMaxBranching(t *testing.T) {
+	t.Run("test a node with 100 moves and a cap of 10 gets 10 children", func(t *testing.T) {
+		expectimax := NewExpectimax(newEndlessGame(100), endlessHeuristic, uniformChildLikelihood, 100000, WithMaxBranching(10))
+		exploreSteps(expectimax, 1)
+
+		if count := len(expectimax.rootNode.children); count != 10 {
+			t.Fatalf("Root has %d children, expected 10.", count)
+		}
+		for move := 0; move < 10; move++ {
+			if _, ok := expectimax.rootNode.children[move]; !ok {
+				t.Errorf("Root is missing its first listed move %d.", move)
+			}
+		}
+	})
+
+	t.Run("test the cap keeps a MoveOrderingGame's highest priority moves", func(t *testing.T) {
+		game := &priorityEndlessGame{newEndlessGame(100)}
+		expectimax := NewExpectimax(game, priorityEndlessHeuristic, uniformChildLikelihood, 100000, WithMaxBranching(10))
+		exploreSteps(expectimax, 1)
+
+		if count := len(expectimax.rootNode.children); count != 10 {
+			t.Fatalf("Root has %d children, expected 10.", count)
+		}
+		for move := 90; move < 100; move++ {
+			if _, ok := expectimax.rootNode.children[move]; !ok {
+				t.Errorf("Root is missing its high priority move %d.", move)
+			}
+		}
+	})
+}
+
+func TestProgressiveWidening(t *testing.T) {
+	t.Run("test wide nodes start small and widen with visits", func(t *testing.T) {
+		game := &priorityEndlessGame{newEndlessGame(1000)}
+		expectimax := NewExpectimax(game, priorityEndlessHeuristic, uniformChildLikelihood, 100000, WithProgressiveWidening(5, 10))
+		exploreSteps(expectimax, 1)
+
+		rootNode := expectimax.rootNode
+		if len(rootNode.children) != 5 || len(rootNode.pendingMoves) != 995 {
+			t.Fatalf("Root started with %d children and %d pending moves, expected 5 and 995.", len(rootNode.children), len(rootNode.pendingMoves))
+		}
+		for move := 995; move < 1000; move++ {
+			if _, ok := rootNode.children[move]; !ok {
+				t.Errorf("Root started without its high priority move %d.", move)
+			}
+		}
+
+		exploreSteps(expectimax, 199)
+		if rootNode.visitCount != 200 {
+			t.Errorf("Root has %d visits, expected 200.", rootNode.visitCount)
+		}
+		if len(rootNode.children) != 25 {
+			t.Errorf("Root has %d children after 200 visits, expected 5 plus one per 10 visits.", len(rootNode.children))
+		}
+		if _, ok := rootNode.children[975]; !ok {
+			t.Errorf("Root didn't widen in priority order.")
+		}
+
+		counts := map[string]int{}
+		rootNode.countExplorationStatus(counts)
+		nodeCount := 0
+		for _, count := range counts {
+			nodeCount += count
+		}
+		if nodeCount != rootNode.descendentCount+1 {
+			t.Errorf("Tree holds %d nodes, but the root counts %d descendents.", nodeCount, rootNode.descendentCount)
+		}
+	})
+}
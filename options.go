@@ -0,0 +1,652 @@
+package expectimax
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// ExpectimaxOption configures optional behaviour when constructing an Expectimax.
+type ExpectimaxOption func(*Expectimax)
+
+// WithAlternatingPerspective treats the game as two-player zero-sum. Heuristic and
+// node values are always from player 0's point of view; at nodes where a
+// MultiplayerGame reports any other player to move, the child likelihood
+// function is given negated child values so it can pick the opponent's best
+// reply as the highest value. GetBestMove picks the move that is best for the
+// player to move at the root, while GetNextMoveValues still reports player 0's
+// values.
+func WithAlternatingPerspective(enabled bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.alternatingPerspective = enabled
+	}
+}
+
+// WithOnExplore registers a callback fired on the main loop each time an explored
+// node is processed, with the move that led to the node, its number of children
+// and its backed-up value. The search is stalled while the callback runs, so it
+// must return quickly and must not call back into the Expectimax.
+func WithOnExplore(onExplore func(move interface{}, childCount int, value float64)) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.onExplore = onExplore
+	}
+}
+
+// WithOnBestMoveChange registers a callback fired on the main loop whenever the
+// root's best move changes, including when the root's moves are first found and
+// after each move made on the game. Like WithOnExplore's callback, it must
+// return quickly and must not call back into the Expectimax.
+func WithOnBestMoveChange(onBestMoveChange func(change BestMoveChange)) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.onBestMoveChange = onBestMoveChange
+	}
+}
+
+// WithSearchTimeout bounds how long Search waits for the search to go idle.
+func WithSearchTimeout(timeout time.Duration) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.searchTimeout = timeout
+	}
+}
+
+// WithPossibleMoveCaching stores each child's possible moves when the child is
+// created, while its game is at hand, so exploring it later doesn't ask the
+// game rebuilt by GetGame to generate them again. GetPossibleMoves must return a
+// slice that isn't modified afterwards, such as a fresh copy.
+func WithPossibleMoveCaching(enabled bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.cachePossibleMoves = enabled
+	}
+}
+
+// WithMaterializeInterval keeps the game of one node in every interval along each
+// line, so GetGame never replays more than interval-1 moves from the nearest kept
+// game. It's a lighter form of WithGameCaching, which is the same as an interval
+// of 1, trading less memory for some replay. 0, the default, keeps none.
+func WithMaterializeInterval(interval int) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if interval < 0 {
+			log.Printf("expectimax: materialize interval %d is negative, using 0", interval)
+			interval = 0
+		}
+		this.settings.materializeInterval = interval
+	}
+}
+
+// WithGameCaching keeps the game each child was created from, so GetGame and
+// moving the root down the tree use it rather than cloning the root's game and
+// replaying the moves to it. It trades a game per node of memory for the replay.
+func WithGameCaching(enabled bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.cacheGames = enabled
+	}
+}
+
+// WithResponsePollInterval sets how long GetBestMove and GetNextMoveValues wait
+// before checking again whether the search is deep enough to answer. Intervals
+// below a millisecond are raised to a millisecond.
+func WithResponsePollInterval(interval time.Duration) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if interval < minResponsePollInterval {
+			log.Printf("expectimax: response poll interval %v is too small, using %v", interval, minResponsePollInterval)
+			interval = minResponsePollInterval
+		}
+		this.responsePollInterval = interval
+	}
+}
+
+// WithSimultaneousSolution selects how simultaneous nodes of a SimultaneousGame
+// are valued. The default is SimultaneousEquilibrium.
+func WithSimultaneousSolution(solution SimultaneousSolution) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.simultaneousSolution = solution
+	}
+}
+
+// WithDrawValue sets the value given to drawn positions, such as a HashableGame
+// repeating a position earlier on the path from the root. The default is 0.
+func WithDrawValue(drawValue float64) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.drawValue = drawValue
+	}
+}
+
+// WithOutcomeValues values the drawn and lost games of an OutcomeGame at
+// drawValue and lossValue, from player 0's point of view, so the search prefers
+// a draw to a loss when it can't find a win. drawValue also replaces
+// WithDrawValue's value for repeated positions, as they are draws too.
+func WithOutcomeValues(drawValue float64, lossValue float64) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if lossValue >= drawValue {
+			log.Printf("expectimax: loss value %g is not below draw value %g, so losses won't be avoided", lossValue, drawValue)
+		}
+		this.settings.outcomeValues = true
+		this.settings.drawValue = drawValue
+		this.settings.lossValue = lossValue
+	}
+}
+
+// WithEqualCycleDetection values positions that repeat one on the path from the
+// root as draws, using Equal, for an EqualGame that isn't a HashableGame. Each
+// Explore then rebuilds the games along the node's path and compares every child
+// with them, so it costs time in proportion to the depth. Hashable games always
+// detect repetitions by hash.
+func WithEqualCycleDetection(enabled bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.equalCycleDetection = enabled
+	}
+}
+
+// WithWorkerStats has each explore worker record how many nodes it explored and
+// how long each Explore call took, for reporting through WorkerStats.
+func WithWorkerStats(enabled bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if !enabled {
+			this.workerStats = nil
+			return
+		}
+
+		this.workerStats = make([]*workerStatistics, expectimaxWorkerCount)
+		for i := range this.workerStats {
+			this.workerStats[i] = &workerStatistics{}
+		}
+	}
+}
+
+// WithMoveHeuristic evaluates states with a heuristic that is also given the move
+// that led to them, in place of the heuristic passed to the constructor.
+func WithMoveHeuristic(moveHeuristic ExpectimaxMoveHeuristic) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.moveHeuristic = moveHeuristic
+	}
+}
+
+// WithPrior shapes where the search goes at each decision node by prior, called
+// once when the node is explored. The prior, scaled to sum to 1 over the node's
+// moves, takes weight of the share the child likelihoods would otherwise have of
+// each move's explore probability. It doesn't change any values. weight is
+// clamped to [0, 1].
+func WithPrior(prior ExpectimaxPriorFunc, weight float64) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if weight < 0.0 || weight > 1.0 {
+			clamped := math.Max(0.0, math.Min(1.0, weight))
+			log.Printf("expectimax: prior weight %g is outside [0, 1], using %g", weight, clamped)
+			weight = clamped
+		}
+		this.settings.prior = prior
+		this.settings.priorWeight = weight
+	}
+}
+
+// WithUncertaintyWeighting spends more of the search on moves whose outcome is
+// still uncertain. Each node tracks the spread, as a standard deviation, of its
+// children's values; a move whose children all agree is close to decided, while
+// one whose children differ widely could still go either way. Each move's share
+// of the spread beneath its siblings takes weight of the share the child
+// likelihoods would otherwise have of its explore probability. Moves not yet
+// searched past have no spread to go on. It doesn't change any values. weight is
+// clamped to [0, 1].
+func WithUncertaintyWeighting(weight float64) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if weight < 0.0 || weight > 1.0 {
+			clamped := math.Max(0.0, math.Min(1.0, weight))
+			log.Printf("expectimax: uncertainty weight %g is outside [0, 1], using %g", weight, clamped)
+			weight = clamped
+		}
+		this.settings.uncertaintyWeight = weight
+	}
+}
+
+// WithCompensatedSummation backs values up with Kahan summation, so the rounding
+// error of adding up many small weighted values, as at a wide chance node, doesn't
+// build up enough to change which move looks best. It makes each backup a little
+// slower.
+func WithCompensatedSummation(enabled bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.compensatedSummation = enabled
+	}
+}
+
+// WithOnNodeCreate attaches data of the caller's own to every node as it's made,
+// such as an ID in an external store. The search never looks at it, but it's
+// given to WalkUserData and ExportTree. onNodeCreate is called on the explore
+// workers, so it must be safe to call concurrently.
+func WithOnNodeCreate(onNodeCreate func() interface{}) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.onNodeCreate = onNodeCreate
+	}
+}
+
+// WithProofPropagation proves outcomes up the tree from finished games that an
+// OutcomeGame reports won, drawn or lost, treating repeated positions as draws.
+// A decision node with a move proven to win for the player to move is proven a
+// win, and one with every move proven takes the best of them. A chance node is
+// proven when all its outcomes are proven the same. Proven subtrees aren't
+// searched any further, and a proven win's move is given all the likelihood.
+// ProvenOutcome reports what's been proven at the root.
+func WithProofPropagation(enabled bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.proofPropagation = enabled
+	}
+}
+
+// WithHeuristicBlend mixes a node's own heuristic into its backed-up value, to
+// steady the values of thinly searched subtrees. The heuristic's weight is
+// descendents/(descendents+descendentCount), so it carries half the value when
+// the subtree has grown to descendents nodes and fades as it grows further. 0,
+// the default, uses the backed-up value alone.
+func WithHeuristicBlend(descendents float64) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if descendents < 0.0 {
+			log.Printf("expectimax: heuristic blend %g is negative, using 0", descendents)
+			descendents = 0.0
+		}
+		this.settings.heuristicBlend = descendents
+	}
+}
+
+// WithMinResponseFraction sets the fraction of maxNodeCount that must be explored
+// before GetBestMove and GetNextMoveValues answer, unless the tree is exhausted
+// first. The default is 0.01. Values outside [0, 1] are clamped.
+func WithMinResponseFraction(fraction float64) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if math.IsNaN(fraction) {
+			log.Printf("expectimax: minimum response fraction is NaN, using %g", defaultMinResponseFraction)
+			fraction = defaultMinResponseFraction
+		} else if fraction < 0.0 || fraction > 1.0 {
+			clamped := math.Max(0.0, math.Min(1.0, fraction))
+			log.Printf("expectimax: minimum response fraction %g is outside [0, 1], using %g", fraction, clamped)
+			fraction = clamped
+		}
+		this.minResponseFraction = fraction
+		this.minResponseNodes = -1
+	}
+}
+
+// WithMinResponseNodes sets the number of nodes that must be explored before
+// GetBestMove and GetNextMoveValues answer, in place of WithMinResponseFraction.
+func WithMinResponseNodes(nodeCount int) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if nodeCount < 0 {
+			log.Printf("expectimax: minimum response nodes %d is negative, using 0", nodeCount)
+			nodeCount = 0
+		}
+		this.minResponseNodes = nodeCount
+	}
+}
+
+// WithValueScale sets how heuristic and node values are interpreted. The default
+// is ScoreValues. Probability scales don't combine with WithAlternatingPerspective,
+// which negates values rather than taking their complement.
+func WithValueScale(scale ValueScale) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.valueScale = scale
+	}
+}
+
+// WithErrorCallback registers a callback fired on the main loop for problems met
+// during the search that don't stop it, such as an Explore running past
+// WithExploreTimeout or a move from the game the search can't follow. Without
+// one they are logged. The callback must return
+// quickly and must not call back into the Expectimax.
+func WithErrorCallback(onError func(err error)) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.onError = onError
+	}
+}
+
+// WithWatchdog watches for the search stalling, such as from a heuristic that
+// never returns or a worker that's blocked: no nodes explored for timeout while
+// there's work in flight, or the main loop not answering. A stall is reported to
+// the error callback from the watchdog's own goroutine, so the callback must be
+// safe to call alongside the main loop. With stop set, the search is stopped
+// too. An idle search, with nothing left to explore, isn't a stall.
+func WithWatchdog(timeout time.Duration, stop bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if timeout <= 0 {
+			log.Printf("expectimax: watchdog timeout %v is not positive, using no watchdog", timeout)
+			timeout = 0
+		}
+		this.watchdogTimeout = timeout
+		this.watchdogStop = stop
+	}
+}
+
+// WithExploreTimeout limits how long exploring a single node may take, so a
+// heuristic that hangs on some position can't hold up a worker indefinitely. A
+// node that takes longer is left as a leaf valued by its own heuristic, and the
+// error callback is told. The abandoned work carries on in the background until
+// it finishes, but its results are thrown away. 0, the default, means no limit.
+func WithExploreTimeout(timeout time.Duration) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if timeout < 0 {
+			log.Printf("expectimax: explore timeout %v is negative, using no limit", timeout)
+			timeout = 0
+		}
+		this.settings.exploreTimeout = timeout
+	}
+}
+
+// WithMoveValidation checks each move from GetPossibleMoves with IsValidMove
+// before exploring it, to catch bugs in a game's move generation. Invalid moves
+// are left out of the tree and reported to the error callback. It costs an extra
+// IsValidMove call per move, so it's off by default.
+func WithMoveValidation(enabled bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.settings.validateMoves = enabled
+	}
+}
+
+// WithExploredNodeBufferSize sets how many explored nodes can queue up waiting
+// for the main loop to process them. Once the queue is full, workers block until
+// the main loop catches up, so results are never dropped. The default is 10 per
+// worker; 0 makes each worker wait for the main loop to take its node.
+func WithExploredNodeBufferSize(size int) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if size < 0 {
+			log.Printf("expectimax: explored node buffer size %d is negative, using 0", size)
+			size = 0
+		}
+		this.exploredNodeBufferSize = size
+	}
+}
+
+// WithMoveListenerBufferSize sets how many moves the game can make before
+// waiting for the main loop to follow them, 4 by default. The main loop also
+// takes moves off the listener between the steps of a long synchronous search,
+// such as RunSynchronous, so the game only waits while a single step runs or
+// while RunExpectimax isn't running. Moves are never dropped.
+func WithMoveListenerBufferSize(size int) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if size < 0 {
+			log.Printf("expectimax: move listener buffer size %d is negative, using 0", size)
+			size = 0
+		}
+		this.moveListenerBufferSize = size
+	}
+}
+
+// WithProgressiveWidening keeps decision nodes with many moves from being
+// expanded all at once. A node starts with children for only its first
+// initialChildren moves, best first for a MoveOrderingGame, and gains another
+// each time visitsPerChild more explorations have been processed beneath it, or
+// whenever it has nothing else left to explore. Chance and simultaneous nodes
+// are always expanded fully. initialChildren of 0, the default, turns it off.
+func WithProgressiveWidening(initialChildren int, visitsPerChild int) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if initialChildren < 0 {
+			log.Printf("expectimax: progressive widening initial children %d is negative, turning widening off", initialChildren)
+			initialChildren = 0
+		}
+		if visitsPerChild < 1 {
+			log.Printf("expectimax: progressive widening visits per child %d is less than 1, using 1", visitsPerChild)
+			visitsPerChild = 1
+		}
+		this.settings.widening = progressiveWidening{initialChildren, visitsPerChild}
+	}
+}
+
+// WithMaxBranching expands at most maxBranching moves at a decision node: the
+// highest priority ones for a MoveOrderingGame, or the first ones listed
+// otherwise. The rest are never searched, which bounds the memory a single
+// Explore can take but loses any strong move that's left out, so the cap is
+// only as good as the game's move ordering. Chance and simultaneous nodes are
+// always expanded in full. 0, the default, means no limit.
+func WithMaxBranching(maxBranching int) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if maxBranching < 0 {
+			log.Printf("expectimax: max branching %d is negative, using no limit", maxBranching)
+			maxBranching = 0
+		}
+		this.settings.maxBranching = maxBranching
+	}
+}
+
+// WithMaxDepth stops the search maxDepth moves below the root, valuing the
+// positions there by their heuristic without expanding them. Depth is counted
+// from the root when a position is explored, so positions cut off before a move
+// is made stay leaves after it. 0, the default, means no limit.
+func WithMaxDepth(maxDepth int) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if maxDepth < 0 {
+			log.Printf("expectimax: max depth %d is negative, using no limit", maxDepth)
+			maxDepth = 0
+		}
+		this.settings.maxDepth = maxDepth
+	}
+}
+
+// WithTranspositionTable keeps the heuristic value of every HashableGame position
+// evaluated for the rest of the game, rather than just the current search, so a
+// position reached again, even after the root has moved past it, is valued from
+// the table instead of the heuristic. Positions with the same hash are taken to
+// be the same, unless the game is an EqualGame, in which case the table keeps
+// each position's game and only matches a position it's Equal to. Once the table
+// holds more than maxEntries, the positions no longer in the search tree are
+// dropped each time the root moves. It does nothing with WithMoveHeuristic. 0, the
+// default, turns it off.
+func WithTranspositionTable(maxEntries int) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if maxEntries < 0 {
+			log.Printf("expectimax: transposition table size %d is negative, using 0", maxEntries)
+			maxEntries = 0
+		}
+		this.settings.transpositions = nil
+		if maxEntries > 0 {
+			this.settings.transpositions = newTranspositionTable(maxEntries)
+		}
+	}
+}
+
+// WithStopCondition registers a predicate checked on the main loop each time an
+// explored node is processed. Once it returns true, no more nodes are handed to
+// the workers and the search reports itself finished, until a move is made or
+// the game is restarted. The predicate must return quickly and must not call
+// back into the Expectimax.
+func WithStopCondition(stopCondition func(stats SearchStats) bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.stopCondition = stopCondition
+	}
+}
+
+// WithMoveHistoryRecording keeps a record of the search's values for each
+// position a move is made from, for MoveHistory, e.g. to collect training data.
+// The history starts again with Restart.
+func WithMoveHistoryRecording(enabled bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.recordMoveHistory = enabled
+	}
+}
+
+// WithResignThreshold makes ShouldResign true once the root's value, from player
+// 0's point of view, has been below threshold when the root moved on for
+// persistence moves in a row. A value back at or above the threshold starts the
+// count again, as does Restart.
+func WithResignThreshold(threshold float64, persistence int) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if persistence <= 0 {
+			log.Printf("expectimax: resign persistence %d is not positive, using 1", persistence)
+			persistence = 1
+		}
+		this.resignThreshold = threshold
+		this.resignPersistence = persistence
+	}
+}
+
+// WithOnBudgetReached registers a callback fired on the main loop the first time
+// the search of each root has a node to explore but no budget left for it, with
+// the stats as they stand, so the results can be collected straight away rather
+// than by polling IsCurrentlySearching. Like WithOnExplore's callback, it must
+// return quickly and must not call back into the Expectimax.
+func WithOnBudgetReached(onBudgetReached func(stats SearchStats)) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.onBudgetReached = onBudgetReached
+	}
+}
+
+// WithDefaultDeadline stops handing out work once the current root has been
+// searched for deadline, so GetBestMove answers within about deadline of each
+// move even when the budget hasn't been reached. The search starts again for
+// the next root. Unlike WithSearchTimeout for Search, it applies to the
+// continuous search. 0, the default, means no limit.
+func WithDefaultDeadline(deadline time.Duration) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if deadline < 0 {
+			log.Printf("expectimax: default deadline %v is negative, using no limit", deadline)
+			deadline = 0
+		}
+		this.defaultDeadline = deadline
+	}
+}
+
+// WithRand sets the source of the engine's random decisions, such as SampleMove.
+// The default is a math/rand generator seeded from the clock.
+func WithRand(random Rand) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if random == nil {
+			log.Printf("expectimax: no Rand given, using math/rand")
+			random = newDefaultRand()
+		}
+		this.rand = random
+	}
+}
+
+// RootSelectionPolicy selects which of the root's moves is the best move.
+type RootSelectionPolicy int
+
+const (
+	// SelectMaxValue picks the move with the highest value.
+	SelectMaxValue RootSelectionPolicy = iota
+	// SelectMostVisited picks the move with the most searched beneath it, which
+	// is steadier from one query to the next than the highest value.
+	SelectMostVisited
+)
+
+// WithRootSelectionPolicy sets how GetBestMove, the principal variation and the
+// best move reported elsewhere are chosen from the root's moves. It doesn't
+// change the search itself. The default is SelectMaxValue.
+func WithRootSelectionPolicy(policy RootSelectionPolicy) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.rootSelectionPolicy = policy
+	}
+}
+
+// WithRootChildFloor makes sure each of the root's moves gets at least nodes
+// nodes searched beneath it, so a move that looks poor at first isn't starved by
+// the best-first search before a deeper look. Moves short of the floor take
+// turns being explored, and the search goes best-first once all have it or the
+// node budget runs out. 0, the default, means no floor.
+func WithRootChildFloor(nodes int) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if nodes < 0 {
+			log.Printf("expectimax: root child floor %d is negative, using 0", nodes)
+			nodes = 0
+		}
+		this.rootChildFloor = nodes
+	}
+}
+
+// WithKeepSubtree keeps the subtrees of the root's other moves that keep selects
+// when the root moves down, rather than deleting them with the rest of the old
+// tree, so their analysis stays available through ExportKeptSubtree. Only the
+// old root's moves are offered, and what's kept is freed at the next descent.
+func WithKeepSubtree(keep func(move interface{}) bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.keepSubtree = keep
+	}
+}
+
+// WithRandomTieBreaking breaks ties between equally good and equally searched
+// best moves with the Expectimax's Rand, instead of taking the move the game
+// lists first.
+func WithRandomTieBreaking(enabled bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.randomTieBreaking = enabled
+	}
+}
+
+// WithRootExploredOnCreate calls EnsureRootExplored when the Expectimax is
+// created, so the root's moves are known before RunExpectimax starts.
+func WithRootExploredOnCreate(enabled bool) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.exploreRootOnCreate = enabled
+	}
+}
+
+// WithConvergence sets what IsConverging looks for: the best root move's value
+// changing by no more than threshold over the last window processed nodes. The
+// default is a change of at most 0.01 over 100 nodes.
+func WithConvergence(window int, threshold float64) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if window <= 0 {
+			log.Printf("expectimax: convergence window %d is not positive, using %d", window, defaultConvergenceWindow)
+			window = defaultConvergenceWindow
+		}
+		if threshold < 0.0 {
+			log.Printf("expectimax: convergence threshold %g is negative, using 0", threshold)
+			threshold = 0.0
+		}
+		this.convergence = newConvergenceTracker(window, threshold)
+	}
+}
+
+// ExhaustedPolicy selects what the workers do once every position within reach
+// has been explored, so there's nothing left to search.
+type ExhaustedPolicy int
+
+const (
+	// StopWhenExhausted parks the workers and reports the search as done, as the
+	// tree's values are exact. The workers start again as soon as there's
+	// something to explore, such as after Restart.
+	StopWhenExhausted ExhaustedPolicy = iota
+	// PollWhenExhausted keeps the workers checking for work every millisecond.
+	PollWhenExhausted
+)
+
+// WithExhaustedPolicy sets what the workers do once the tree is fully explored.
+// The default is StopWhenExhausted.
+func WithExhaustedPolicy(policy ExhaustedPolicy) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.exhaustedPolicy = policy
+	}
+}
+
+// WithCleanupConcurrency bounds the background deletion of trees discarded when
+// the root moves: at most workers goroutines delete trees at once, and once
+// maxQueued trees are waiting for them, moving the root blocks until one is
+// taken. The default is 1 goroutine and 64 queued trees.
+func WithCleanupConcurrency(workers int, maxQueued int) ExpectimaxOption {
+	return func(this *Expectimax) {
+		if workers <= 0 {
+			log.Printf("expectimax: cleanup concurrency %d is not positive, using %d", workers, defaultCleanupWorkerCount)
+			workers = defaultCleanupWorkerCount
+		}
+		if maxQueued <= 0 {
+			log.Printf("expectimax: cleanup queue limit %d is not positive, using %d", maxQueued, defaultCleanupQueueLimit)
+			maxQueued = defaultCleanupQueueLimit
+		}
+		this.cleaner.maxWorkers = workers
+		this.cleaner.maxQueued = maxQueued
+	}
+}
+
+// ExplorationOrder selects how the search picks the next node to explore.
+type ExplorationOrder int
+
+const (
+	// BestFirst explores the most likely unexplored node first, so the search
+	// goes deepest down the lines most likely to be played.
+	BestFirst ExplorationOrder = iota
+	// BreadthFirst explores nodes in the order they were found, so every node at
+	// one depth is handed out before any deeper one, as for exhaustive analysis
+	// of the first few moves. WithRootChildFloor has no effect with it.
+	BreadthFirst
+)
+
+// WithExplorationOrder sets how the search picks the next node to explore. The
+// default is BestFirst.
+func WithExplorationOrder(order ExplorationOrder) ExpectimaxOption {
+	return func(this *Expectimax) {
+		this.explorationOrder = order
+	}
+}
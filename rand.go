@@ -0,0 +1,68 @@
+package expectimax
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Rand is the source of the engine's random decisions. *math/rand.Rand satisfies
+// it, but any generator can be used, e.g. for reproducible self-play. It is only
+// used from the main loop, so it needn't be safe for concurrent use.
+type Rand interface {
+	Float64() float64
+	Intn(n int) int
+}
+
+func newDefaultRand() Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// getOrderedChildMoves returns the moves of the node's children in the order the
+// game lists them, so random choices between them are reproducible.
+func (node *expectimaxNode) getOrderedChildMoves() []interface{} {
+	game := node.GetGame()
+	if game == nil {
+		return nil
+	}
+
+	var possibleMoves []interface{}
+	if simultaneousGame, ok := game.(SimultaneousGame); ok {
+		_, _, jointMoves := getJointMoves(simultaneousGame)
+		possibleMoves = *jointMoves
+	} else {
+		possibleMoves = *game.GetPossibleMoves()
+	}
+
+	childMoves := make([]interface{}, 0, len(node.children))
+	for _, move := range possibleMoves {
+		if _, ok := node.children[move]; ok {
+			childMoves = append(childMoves, move)
+		}
+	}
+
+	return childMoves
+}
+
+// SampleMove picks one of the root's moves at random, each with its likelihood of
+// being played, using the Expectimax's Rand. It returns nil if the root hasn't
+// been explored.
+func (this *Expectimax) SampleMove() interface{} {
+	var sampledMove interface{}
+	this.runOnMainLoop(func() {
+		childMoves := this.rootNode.getOrderedChildMoves()
+		if len(childMoves) == 0 {
+			return
+		}
+
+		remaining := this.rand.Float64() * this.rootNode.childLikelihood.GetTotalValue()
+		for _, move := range childMoves {
+			sampledMove = move
+			remaining -= this.rootNode.childLikelihood[move]
+			if remaining < 0.0 {
+				return
+			}
+		}
+	})
+
+	return sampledMove
+}
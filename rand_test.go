@@ -0,0 +1,48 @@
+package expectimax
+
+import "testing"
+
+// stubRand returns its values in turn, over and over.
+type stubRand struct {
+	values []float64
+	next   int
+}
+
+func (random *stubRand) Float64() float64 {
+	value := random.values[random.next%len(random.values)]
+	random.next++
+	return value
+}
+
+func (random *stubRand) Intn(n int) int {
+	return int(random.Float64() * float64(n))
+}
+
+func TestSampleMove(t *testing.T) {
+	t.Run("test a stub Rand samples moves deterministically", func(t *testing.T) {
+		sample := func() []interface{} {
+			random := &stubRand{values: []float64{0.1, 0.5, 0.9, 0.5}}
+			expectimax := NewExpectimax(newTestGame(uniformTree(3, 2)), testHeuristic, uniformChildLikelihood, 1000, WithRand(random))
+			if move := expectimax.SampleMove(); move != nil {
+				t.Errorf("SampleMove() returned %v before the root was explored, expected nil.", move)
+			}
+
+			exploreAll(expectimax)
+			var moves []interface{}
+			for i := 0; i < 4; i++ {
+				moves = append(moves, expectimax.SampleMove())
+			}
+			return moves
+		}
+
+		expected := []interface{}{0, 1, 2, 1}
+		for run := 0; run < 3; run++ {
+			moves := sample()
+			for i := range expected {
+				if moves[i] != expected[i] {
+					t.Fatalf("Run %d sampled %v, expected %v.", run, moves, expected)
+				}
+			}
+		}
+	})
+}
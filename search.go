@@ -0,0 +1,86 @@
+package expectimax
+
+import (
+	"time"
+)
+
+// searchPollInterval is how often Search checks whether the search has finished.
+const searchPollInterval = 10 * time.Millisecond
+
+// SearchResult is the outcome of a blocking Search.
+type SearchResult struct {
+	BestMove           interface{}
+	Value              float64
+	NodeCount          int
+	AverageDepth       float64
+	PrincipalVariation []interface{}
+}
+
+// Search starts RunExpectimax if it hasn't been started yet, blocks until the
+// search goes idle or the WithSearchTimeout deadline passes, then returns the
+// current best move and its analysis. The search keeps running afterwards so it
+// can follow moves made on the game; call Stop to shut it down. Once the search
+// has been stopped or the game is over, it isn't started again, and Search just
+// returns the current result.
+func (this *Expectimax) Search() SearchResult {
+	if this.startMainLoop() {
+		go this.runMainLoop()
+	}
+
+	var deadline time.Time
+	if this.searchTimeout > 0 {
+		deadline = time.Now().Add(this.searchTimeout)
+	}
+
+	for this.IsCurrentlySearching() && (deadline.IsZero() || time.Now().Before(deadline)) {
+		time.Sleep(searchPollInterval)
+	}
+
+	var result SearchResult
+	this.runOnMainLoop(func() {
+		result = SearchResult{
+			BestMove:           this.getBestChildMove(),
+			Value:              this.rootNode.value,
+			NodeCount:          this.rootNode.descendentCount,
+			AverageDepth:       this.rootNode.averageDepth,
+			PrincipalVariation: this.getPrincipalVariation(),
+		}
+	})
+
+	return result
+}
+
+// timeSafetyMargin is the fraction of each move's time allocation held back to
+// cover the cost of answering.
+const timeSafetyMargin float64 = 0.1
+
+// GetBestMoveTimed spends an even share of the remaining clock, less a safety
+// margin, searching and then returns the best move found. It returns early if
+// the search goes idle. It does not wait for the search to be deep enough, so
+// with a tiny allocation the move may be poorly analysed, or nil if the root
+// hasn't been explored yet.
+func (this *Expectimax) GetBestMoveTimed(remaining time.Duration, movesLeft int) interface{} {
+	if movesLeft < 1 {
+		movesLeft = 1
+	}
+
+	allocation := time.Duration(float64(remaining/time.Duration(movesLeft)) * (1.0 - timeSafetyMargin))
+	deadline := time.Now().Add(allocation)
+	for this.IsCurrentlySearching() {
+		untilDeadline := time.Until(deadline)
+		if untilDeadline <= 0 {
+			break
+		}
+		if untilDeadline > searchPollInterval {
+			untilDeadline = searchPollInterval
+		}
+		time.Sleep(untilDeadline)
+	}
+
+	var bestMove interface{}
+	this.runOnMainLoop(func() {
+		bestMove = this.getBestChildMove()
+	})
+
+	return bestMove
+}
@@ -0,0 +1,157 @@
+package expectimax
+
+import (
+	"math"
+
+	"github.com/andrew-j-armstrong/go-extensions"
+)
+
+// SimultaneousSolution selects how the value of a simultaneous node is computed
+// from its matrix of joint move values.
+type SimultaneousSolution int
+
+const (
+	// SimultaneousEquilibrium plays the mixed strategy equilibrium of the matrix game.
+	SimultaneousEquilibrium SimultaneousSolution = iota
+	// SimultaneousMaxMin has player 0 play the pure move with the best guaranteed
+	// value and player 1 reply with its best response.
+	SimultaneousMaxMin
+)
+
+// fictitiousPlayIterations is the number of rounds of fictitious play used to
+// approximate the equilibrium of matrix games larger than 2x2.
+const fictitiousPlayIterations int = 2000
+
+// solveMatrixGame returns the strategies of the row (maximising) and column
+// (minimising) players for a zero-sum matrix game.
+func solveMatrixGame(payoff [][]float64, solution SimultaneousSolution) ([]float64, []float64) {
+	rowCount, columnCount := len(payoff), len(payoff[0])
+	rowStrategy := make([]float64, rowCount)
+	columnStrategy := make([]float64, columnCount)
+
+	// A saddle point is a pure equilibrium, and the best row is the max-min choice
+	maxMinRow, maxMinValue := 0, math.Inf(-1)
+	for row := range payoff {
+		rowMin := math.Inf(1)
+		for _, value := range payoff[row] {
+			rowMin = math.Min(rowMin, value)
+		}
+		if rowMin > maxMinValue {
+			maxMinRow, maxMinValue = row, rowMin
+		}
+	}
+
+	minMaxColumn, minMaxValue := 0, math.Inf(1)
+	for column := 0; column < columnCount; column++ {
+		columnMax := math.Inf(-1)
+		for row := range payoff {
+			columnMax = math.Max(columnMax, payoff[row][column])
+		}
+		if columnMax < minMaxValue {
+			minMaxColumn, minMaxValue = column, columnMax
+		}
+	}
+
+	if maxMinValue == minMaxValue {
+		rowStrategy[maxMinRow] = 1.0
+		columnStrategy[minMaxColumn] = 1.0
+		return rowStrategy, columnStrategy
+	}
+
+	if solution == SimultaneousMaxMin {
+		bestResponse := 0
+		for column := range payoff[maxMinRow] {
+			if payoff[maxMinRow][column] < payoff[maxMinRow][bestResponse] {
+				bestResponse = column
+			}
+		}
+
+		rowStrategy[maxMinRow] = 1.0
+		columnStrategy[bestResponse] = 1.0
+		return rowStrategy, columnStrategy
+	}
+
+	if rowCount == 2 && columnCount == 2 {
+		a, b, c, d := payoff[0][0], payoff[0][1], payoff[1][0], payoff[1][1]
+		denominator := a - b - c + d
+		rowStrategy[0] = (d - c) / denominator
+		rowStrategy[1] = 1.0 - rowStrategy[0]
+		columnStrategy[0] = (d - b) / denominator
+		columnStrategy[1] = 1.0 - columnStrategy[0]
+		return rowStrategy, columnStrategy
+	}
+
+	// Fictitious play: each player repeatedly best-responds to the other's history
+	rowTotals := make([]float64, rowCount)       // Row payoffs against the column history
+	columnTotals := make([]float64, columnCount) // Column payoffs against the row history
+	row, column := maxMinRow, minMaxColumn
+	for iteration := 0; iteration < fictitiousPlayIterations; iteration++ {
+		rowStrategy[row]++
+		columnStrategy[column]++
+		for i := range rowTotals {
+			rowTotals[i] += payoff[i][column]
+		}
+		for j := range columnTotals {
+			columnTotals[j] += payoff[row][j]
+		}
+
+		for i := range rowTotals {
+			if rowTotals[i] > rowTotals[row] {
+				row = i
+			}
+		}
+		for j := range columnTotals {
+			if columnTotals[j] < columnTotals[column] {
+				column = j
+			}
+		}
+	}
+
+	for i := range rowStrategy {
+		rowStrategy[i] /= float64(fictitiousPlayIterations)
+	}
+	for j := range columnStrategy {
+		columnStrategy[j] /= float64(fictitiousPlayIterations)
+	}
+
+	return rowStrategy, columnStrategy
+}
+
+// getJointMoves returns every pairing of the players' moves in a simultaneous game.
+func getJointMoves(game SimultaneousGame) ([]interface{}, []interface{}, *extensions.InterfaceSlice) {
+	rowMoves := *game.GetPlayerMoves(0)
+	columnMoves := *game.GetPlayerMoves(1)
+
+	jointMoves := make(extensions.InterfaceSlice, 0, len(rowMoves)*len(columnMoves))
+	for _, rowMove := range rowMoves {
+		for _, columnMove := range columnMoves {
+			jointMoves = append(jointMoves, JointMove{rowMove, columnMove})
+		}
+	}
+
+	return rowMoves, columnMoves, &jointMoves
+}
+
+// calculateSimultaneousLikelihood sets each joint move's likelihood to the
+// product of the players' strategies for the node's matrix game.
+func (node *expectimaxNode) calculateSimultaneousLikelihood(solution SimultaneousSolution) {
+	rowMoves, columnMoves := node.simultaneousMoves[0], node.simultaneousMoves[1]
+	if len(rowMoves) == 0 || len(columnMoves) == 0 {
+		return
+	}
+
+	payoff := make([][]float64, len(rowMoves))
+	for row, rowMove := range rowMoves {
+		payoff[row] = make([]float64, len(columnMoves))
+		for column, columnMove := range columnMoves {
+			payoff[row][column] = node.getChildValue(JointMove{rowMove, columnMove})
+		}
+	}
+
+	rowStrategy, columnStrategy := solveMatrixGame(payoff, solution)
+	for row, rowMove := range rowMoves {
+		for column, columnMove := range columnMoves {
+			node.childLikelihood[JointMove{rowMove, columnMove}] = rowStrategy[row] * columnStrategy[column]
+		}
+	}
+}
@@ -0,0 +1,485 @@
+package expectimax
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// nodesPerSecondWindow is the number of one-second samples NodesPerSecond averages over.
+const nodesPerSecondWindow int = 5
+
+type statisticsSample struct {
+	time          time.Time
+	exploredNodes int64
+}
+
+// searchStatistics tracks search throughput. exploredNodes is updated atomically
+// by the main loop so it can be read from other goroutines.
+type searchStatistics struct {
+	exploredNodes int64
+	lock          sync.Mutex
+	samples       []statisticsSample // Oldest first
+}
+
+func (stats *searchStatistics) recordExploredNode() {
+	atomic.AddInt64(&stats.exploredNodes, 1)
+}
+
+func (stats *searchStatistics) getExploredNodes() int64 {
+	return atomic.LoadInt64(&stats.exploredNodes)
+}
+
+func (stats *searchStatistics) sample(now time.Time) {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	stats.samples = append(stats.samples, statisticsSample{now, stats.getExploredNodes()})
+	if len(stats.samples) > nodesPerSecondWindow+1 {
+		stats.samples = stats.samples[len(stats.samples)-nodesPerSecondWindow-1:]
+	}
+}
+
+func (stats *searchStatistics) nodesPerSecond(now time.Time) float64 {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	if len(stats.samples) == 0 {
+		return 0.0
+	}
+
+	oldest := stats.samples[0]
+	elapsed := now.Sub(oldest.time).Seconds()
+	if elapsed <= 0.0 {
+		return 0.0
+	}
+
+	return float64(stats.getExploredNodes()-oldest.exploredNodes) / elapsed
+}
+
+// NodesPerSecond returns the rate at which explored nodes have been processed
+// over the last few seconds.
+func (this *Expectimax) NodesPerSecond() float64 {
+	return this.stats.nodesPerSecond(time.Now())
+}
+
+// ElapsedSinceRoot returns how long the search has run since the root was last
+// set, by a move, AdvanceToState or Restart. With GetNodeCount, it gives the
+// rate the current move's tree has grown at.
+func (this *Expectimax) ElapsedSinceRoot() time.Duration {
+	var elapsed time.Duration
+	this.runOnMainLoop(func() {
+		elapsed = time.Since(this.rootTime)
+	})
+
+	return elapsed
+}
+
+func (stats *searchStatistics) reset(now time.Time) {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	atomic.StoreInt64(&stats.exploredNodes, 0)
+	stats.samples = append(stats.samples[:0], statisticsSample{now, 0})
+}
+
+// ResetStats zeroes the search statistics, e.g. to measure the effort spent on a
+// single move. The search itself is unaffected.
+func (this *Expectimax) ResetStats() {
+	this.stats.reset(time.Now())
+	atomic.StoreInt64(this.settings.heuristicCalls, 0)
+	this.settings.heuristicStats.reset()
+}
+
+// HeuristicCalls returns how many times the heuristic or move heuristic has been
+// called. Compared with GetNodeCount, it shows how much evaluation is redone.
+func (this *Expectimax) HeuristicCalls() int64 {
+	return atomic.LoadInt64(this.settings.heuristicCalls)
+}
+
+// GetNodeCount returns the number of nodes in the tree below the root.
+func (this *Expectimax) GetNodeCount() int {
+	var nodeCount int
+	this.runOnMainLoop(func() {
+		nodeCount = this.rootNode.descendentCount
+	})
+
+	return nodeCount
+}
+
+// DistinctStateCount returns the number of distinct positions the transposition
+// table holds, which is less than GetNodeCount by the positions reached by more
+// than one line. The table keeps positions from before the root moved until it
+// fills, so they're counted too. It returns -1 without WithTranspositionTable.
+func (this *Expectimax) DistinctStateCount() int {
+	if this.settings.transpositions == nil {
+		return -1
+	}
+	return this.settings.transpositions.len()
+}
+
+// GetMaxExploredDepth returns the number of plies in the longest line searched
+// below the root. Compared with the average depth, it shows whether the search
+// is tunnelling down a narrow line or staying broad.
+func (this *Expectimax) GetMaxExploredDepth() int {
+	var maxDepth int
+	this.runOnMainLoop(func() {
+		maxDepth = this.rootNode.maxDepth
+	})
+
+	return maxDepth
+}
+
+// ExplorationStatusCounts returns the number of nodes in the tree in each
+// exploration status, keyed by the status name: "Unexplored",
+// "WaitingForExploration", "Exploring", "Explored" or "Archived".
+func (this *Expectimax) ExplorationStatusCounts() map[string]int {
+	counts := map[string]int{}
+	this.runOnMainLoop(func() {
+		this.rootNode.countExplorationStatus(counts)
+	})
+
+	return counts
+}
+
+// SearchStats describes the search of the current root, for stop conditions.
+type SearchStats struct {
+	NodeCount     int           // Root descendents
+	ExploredNodes int64         // Explorations processed since the stats were last reset
+	Elapsed       time.Duration // Since the root was set
+	AverageDepth  float64
+	Value         float64     // Root value, from player 0's point of view
+	BestMove      interface{} // nil until the root has been explored
+}
+
+// getSearchStats must be called from the main loop.
+func (this *Expectimax) getSearchStats() SearchStats {
+	return SearchStats{
+		NodeCount:     this.rootNode.descendentCount,
+		ExploredNodes: this.stats.getExploredNodes(),
+		Elapsed:       time.Since(this.rootTime),
+		AverageDepth:  this.rootNode.averageDepth,
+		Value:         this.rootNode.value,
+		BestMove:      this.getBestChildMove(),
+	}
+}
+
+// ValueStats summarises a set of values, such as those found beneath a move.
+type ValueStats struct {
+	Mean   float64 // Backed-up expected value, or the average
+	Min    float64 // Lowest leaf value
+	Max    float64 // Highest leaf value
+	StdDev float64 // Standard deviation, for HeuristicValueStats only
+}
+
+// GetMoveValueStats returns the expected value and the range of leaf values in
+// the subtree beneath each of the root's moves.
+func (this *Expectimax) GetMoveValueStats() map[interface{}]ValueStats {
+	moveValueStats := map[interface{}]ValueStats{}
+	this.runOnMainLoop(func() {
+		for childMove, childNode := range this.rootNode.children {
+			moveValueStats[childMove] = ValueStats{Mean: childNode.value, Min: childNode.minValue, Max: childNode.maxValue}
+		}
+	})
+
+	return moveValueStats
+}
+
+// LeafValueHistogram counts the values of the leaves in the subtree beneath the
+// root's move, explored or not, in buckets of equal width spanning the lowest to
+// the highest. The leaf values are copied on the main loop and binned after. It
+// returns nil if the root has no such move or buckets isn't positive.
+func (this *Expectimax) LeafValueHistogram(move interface{}, buckets int) []int {
+	if buckets <= 0 {
+		return nil
+	}
+
+	var leafValues []float64
+	found := false
+	this.runOnMainLoop(func() {
+		if childNode, ok := this.rootNode.children[move]; ok {
+			leafValues = childNode.appendLeafValues(leafValues)
+			found = true
+		}
+	})
+	if !found {
+		return nil
+	}
+
+	histogram := make([]int, buckets)
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for _, value := range leafValues {
+		minValue, maxValue = math.Min(minValue, value), math.Max(maxValue, value)
+	}
+	for _, value := range leafValues {
+		bucket := 0
+		if maxValue > minValue {
+			bucket = int(float64(buckets) * (value - minValue) / (maxValue - minValue))
+		}
+		if bucket >= buckets {
+			bucket = buckets - 1 // The highest value closes the last bucket
+		}
+		histogram[bucket]++
+	}
+
+	return histogram
+}
+
+// heuristicValueStatistics accumulates the heuristic's outputs with Welford's
+// algorithm. The workers update it, so it's locked. A nil one records nothing.
+type heuristicValueStatistics struct {
+	lock     sync.Mutex
+	count    int64
+	mean     float64
+	m2       float64 // Sum of squared differences from the mean
+	min, max float64
+}
+
+func (stats *heuristicValueStatistics) record(value float64) {
+	if stats == nil {
+		return
+	}
+
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	if stats.count == 0 {
+		stats.min, stats.max = value, value
+	} else {
+		stats.min = math.Min(stats.min, value)
+		stats.max = math.Max(stats.max, value)
+	}
+	stats.count++
+	delta := value - stats.mean
+	stats.mean += delta / float64(stats.count)
+	stats.m2 += delta * (value - stats.mean)
+}
+
+func (stats *heuristicValueStatistics) get() ValueStats {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	if stats.count == 0 {
+		return ValueStats{}
+	}
+
+	return ValueStats{Mean: stats.mean, Min: stats.min, Max: stats.max, StdDev: math.Sqrt(stats.m2 / float64(stats.count))}
+}
+
+func (stats *heuristicValueStatistics) copy() *heuristicValueStatistics {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	return &heuristicValueStatistics{count: stats.count, mean: stats.mean, m2: stats.m2, min: stats.min, max: stats.max}
+}
+
+func (stats *heuristicValueStatistics) reset() {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	stats.count, stats.mean, stats.m2, stats.min, stats.max = 0, 0.0, 0.0, 0.0, 0.0
+}
+
+// HeuristicValueStats returns the range, mean and standard deviation of every
+// value the heuristic or move heuristic has returned, to help with scaling it.
+// Exact values from TerminalValue and draws aren't included.
+func (this *Expectimax) HeuristicValueStats() ValueStats {
+	return this.settings.heuristicStats.get()
+}
+
+// WorkerStatBuckets are the upper bounds of the Explore duration histogram in
+// WorkerStat. Durations of at least the last bound fall in a final bucket.
+var WorkerStatBuckets = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// WorkerStat summarises the nodes explored by a single worker.
+type WorkerStat struct {
+	ID            int
+	NodesExplored int
+	TotalTime     time.Duration // Time spent in Explore
+	MaxTime       time.Duration // Slowest single Explore
+	Histogram     []int         // Explore calls per WorkerStatBuckets bucket, plus one for longer calls
+}
+
+type workerStatistics struct {
+	lock sync.Mutex
+	stat WorkerStat
+}
+
+func (stats *workerStatistics) record(duration time.Duration) {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	if stats.stat.Histogram == nil {
+		stats.stat.Histogram = make([]int, len(WorkerStatBuckets)+1)
+	}
+
+	bucket := len(WorkerStatBuckets)
+	for i, bound := range WorkerStatBuckets {
+		if duration < bound {
+			bucket = i
+			break
+		}
+	}
+
+	stats.stat.NodesExplored++
+	stats.stat.TotalTime += duration
+	if duration > stats.stat.MaxTime {
+		stats.stat.MaxTime = duration
+	}
+	stats.stat.Histogram[bucket]++
+}
+
+func (stats *workerStatistics) get(id int) WorkerStat {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+
+	stat := stats.stat
+	stat.ID = id
+	stat.Histogram = make([]int, len(WorkerStatBuckets)+1)
+	copy(stat.Histogram, stats.stat.Histogram)
+	return stat
+}
+
+// WorkerStats returns how many nodes each worker has explored and how long its
+// Explore calls took, or nil unless the Expectimax was built WithWorkerStats.
+// Nodes explored directly by the main loop when a move is made are not included.
+func (this *Expectimax) WorkerStats() []WorkerStat {
+	if this.workerStats == nil {
+		return nil
+	}
+
+	workerStats := make([]WorkerStat, len(this.workerStats))
+	for i, stats := range this.workerStats {
+		workerStats[i] = stats.get(i)
+	}
+
+	return workerStats
+}
+
+const (
+	defaultConvergenceWindow    int     = 100
+	defaultConvergenceThreshold float64 = 0.01
+)
+
+// convergenceTracker keeps the best root move's value after each of the last
+// len(values) processed nodes, to tell whether it's still moving.
+type convergenceTracker struct {
+	values    []float64 // Ring buffer, oldest at next once full
+	next      int
+	full      bool
+	threshold float64 // Largest change over the window that counts as converged
+}
+
+func newConvergenceTracker(window int, threshold float64) convergenceTracker {
+	return convergenceTracker{values: make([]float64, window), threshold: threshold}
+}
+
+func (tracker *convergenceTracker) record(value float64) {
+	if len(tracker.values) == 0 {
+		return
+	}
+
+	tracker.values[tracker.next] = value
+	tracker.next++
+	if tracker.next == len(tracker.values) {
+		tracker.next = 0
+		tracker.full = true
+	}
+}
+
+func (tracker *convergenceTracker) copy() convergenceTracker {
+	copied := *tracker
+	copied.values = append([]float64(nil), tracker.values...)
+	return copied
+}
+
+func (tracker *convergenceTracker) reset() {
+	tracker.next = 0
+	tracker.full = false
+}
+
+// converged reports whether the window is full and its values span no more than
+// the threshold.
+func (tracker *convergenceTracker) converged() bool {
+	if !tracker.full {
+		return false
+	}
+
+	minValue, maxValue := tracker.values[0], tracker.values[0]
+	for _, value := range tracker.values {
+		minValue = math.Min(minValue, value)
+		maxValue = math.Max(maxValue, value)
+	}
+
+	return maxValue-minValue <= tracker.threshold
+}
+
+// IsConverging reports whether the best root move's value has changed by no
+// more than the convergence threshold over the last processed nodes, set by
+// WithConvergence, or whether the tree is fully explored so it can't change at
+// all. It's a hint that further search is unlikely to change the answer.
+func (this *Expectimax) IsConverging() bool {
+	var converging bool
+	this.runOnMainLoop(func() {
+		converging = (this.rootNode.mostLikelyUnexploredDescendent == nil && this.inFlight == 0) || this.convergence.converged()
+	})
+
+	return converging
+}
+
+// Confidence returns a rough measure in [0, 1] of how far the root's value can be
+// trusted. A fully explored tree is exact and scores 1. Otherwise it's
+//
+//	min(nodes / maxNodeCount, 1) * averageDepth / (averageDepth + 1)
+//
+// so it's near 0 when little of the budget has been used, and grows as the
+// budget fills and the tree deepens.
+func (this *Expectimax) Confidence() float64 {
+	var confidence float64
+	this.runOnMainLoop(func() {
+		rootNode := this.rootNode
+		if rootNode.mostLikelyUnexploredDescendent == nil && this.inFlight == 0 {
+			confidence = 1.0
+			return
+		}
+
+		budgetUsed := math.Min(float64(rootNode.descendentCount)/float64(this.maxNodeCount), 1.0)
+		confidence = budgetUsed * rootNode.averageDepth / (rootNode.averageDepth + 1.0)
+	})
+
+	return confidence
+}
+
+// ProvenOutcome returns the outcome for player 0 that WithProofPropagation has
+// proven best play leads to from the root, or UnknownOutcome if nothing's been
+// proven yet.
+func (this *Expectimax) ProvenOutcome() Outcome {
+	var proof Outcome
+	this.runOnMainLoop(func() {
+		proof = this.rootNode.proof
+	})
+
+	return proof
+}
+
+// getBestChildValue returns the value of the root's best move from the point of
+// view of the player choosing it, or 0 if the root has no children yet.
+func (this *Expectimax) getBestChildValue() float64 {
+	bestChildValue := math.Inf(-1)
+	for _, childNode := range this.rootNode.children {
+		bestChildValue = math.Max(bestChildValue, this.rootNode.perspective*childNode.value)
+	}
+	if math.IsInf(bestChildValue, -1) {
+		return 0.0
+	}
+
+	return bestChildValue
+}
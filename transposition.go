@@ -0,0 +1,126 @@
+package expectimax
+
+import (
+	"sync"
+)
+
+// transpositionTable remembers the heuristic value of each HashableGame position
+// evaluated, by hash, for the whole game rather than a single search, so a
+// position reached again after the root has moved isn't evaluated again. The
+// workers share it, so it's guarded by a mutex.
+type transpositionTable struct {
+	mutex      sync.Mutex
+	heuristics map[uint64][]transposition // Several when an EqualGame's hashes collide
+	maxEntries int                        // Size past which positions no longer in the tree are dropped
+	generation int                        // Counts the clears, so values from before one aren't stored after it
+}
+
+// transposition is a position's heuristic value, with its game when it's an
+// EqualGame so a colliding position can be told apart.
+type transposition struct {
+	game      EqualGame
+	heuristic float64
+}
+
+func newTranspositionTable(maxEntries int) *transpositionTable {
+	return &transpositionTable{heuristics: map[uint64][]transposition{}, maxEntries: maxEntries}
+}
+
+// lookup returns the heuristic stored for game's position. An EqualGame only
+// matches positions it's Equal to; any other game matches on hash alone.
+func (table *transpositionTable) lookup(hash uint64, game Game) (float64, bool) {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	equalGame, isEqualGame := game.(EqualGame)
+	for _, entry := range table.heuristics[hash] {
+		if !isEqualGame || (entry.game != nil && equalGame.Equal(entry.game)) {
+			return entry.heuristic, true
+		}
+	}
+	return 0.0, false
+}
+
+// store keeps game's heuristic, unless the table has been cleared since
+// generation, when the value came from a heuristic that's been replaced.
+func (table *transpositionTable) store(hash uint64, game Game, heuristic float64, generation int) {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	if generation != table.generation {
+		return
+	}
+
+	equalGame, isEqualGame := game.(EqualGame)
+	if !isEqualGame {
+		table.heuristics[hash] = []transposition{{heuristic: heuristic}}
+		return
+	}
+	for i, entry := range table.heuristics[hash] {
+		if entry.game != nil && equalGame.Equal(entry.game) {
+			table.heuristics[hash][i].heuristic = heuristic
+			return
+		}
+	}
+	table.heuristics[hash] = append(table.heuristics[hash], transposition{game: equalGame, heuristic: heuristic})
+}
+
+func (table *transpositionTable) len() int {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	entries := 0
+	for _, transpositions := range table.heuristics {
+		entries += len(transpositions)
+	}
+	return entries
+}
+
+// clear empties the table and returns its new generation.
+func (table *transpositionTable) clear() int {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	table.heuristics = map[uint64][]transposition{}
+	table.generation++
+	return table.generation
+}
+
+// prune drops the positions that aren't in root's tree once the table holds more
+// than maxEntries. It must be called from the main loop.
+func (table *transpositionTable) prune(root *expectimaxNode) {
+	if table.len() <= table.maxEntries {
+		return
+	}
+
+	reachable := map[uint64]bool{}
+	root.appendHashes(reachable)
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	for hash := range table.heuristics {
+		if !reachable[hash] {
+			delete(table.heuristics, hash)
+		}
+	}
+}
+
+// appendHashes adds the hashes of the node and its descendents to hashes. It
+// only descends through archived nodes, whose children the workers no longer
+// touch.
+func (node *expectimaxNode) appendHashes(hashes map[uint64]bool) {
+	if !node.incrementReference() {
+		return
+	}
+	defer node.decrementReference()
+
+	if node.hashed {
+		hashes[node.hash] = true
+	}
+	if node.explorationStatus == Archived {
+		for _, childNode := range node.children {
+			childNode.appendHashes(hashes)
+		}
+	}
+}
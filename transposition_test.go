@@ -0,0 +1,99 @@
+package expectimax
+
+import (
+	"testing"
+)
+
+// hashedTree is a tree whose move 1 reaches position 10 again, already seen
+// beneath move 0.
+func hashedTree() *testState {
+	hashed := func(hash uint64, state *testState) *testState {
+		state.hash = hash
+		return state
+	}
+
+	return hashed(1, branch(0.0,
+		hashed(2, branch(1.0, hashed(10, leaf(3.0)), hashed(11, leaf(4.0)))),
+		hashed(3, branch(0.0, hashed(10, leaf(3.0)), hashed(12, leaf(5.0)))),
+	))
+}
+
+func TestTranspositionTable(t *testing.T) {
+	// Explores the root and move 0, then moves the root to move 1 and explores
+	// it, returning the heuristic calls that last exploration took
+	callsAfterMoving := func(options ...ExpectimaxOption) (*Expectimax, int64) {
+		initNodeMemoryPool()
+		expectimax := NewExpectimax(&hashedTestGame{newTestGame(hashedTree())}, testHeuristic, maxChildLikelihood, 1000, options...)
+		exploreSteps(expectimax, 2)
+		if err := expectimax.AdvanceToState([]interface{}{1}); err != nil {
+			t.Fatal(err)
+		}
+
+		calls := expectimax.HeuristicCalls()
+		exploreSteps(expectimax, 1)
+		return expectimax, expectimax.HeuristicCalls() - calls
+	}
+
+	t.Run("test a position seen before the root moved isn't evaluated again", func(t *testing.T) {
+		if _, calls := callsAfterMoving(); calls != 2 {
+			t.Errorf("Without a table, exploring move 1 took %d heuristic calls, expected 2.", calls)
+		}
+
+		expectimax, calls := callsAfterMoving(WithTranspositionTable(100))
+		if calls != 1 {
+			t.Errorf("With a table, exploring move 1 took %d heuristic calls, expected 1 for the new position.", calls)
+		}
+		if value := expectimax.rootNode.children[0].heuristic; value != 3.0 {
+			t.Errorf("Repeated position was valued %g, expected its heuristic 3.", value)
+		}
+	})
+
+	t.Run("test a full table drops positions no longer in the tree", func(t *testing.T) {
+		initNodeMemoryPool()
+		expectimax := NewExpectimax(&hashedTestGame{newTestGame(hashedTree())}, testHeuristic, maxChildLikelihood, 1000, WithTranspositionTable(1))
+		exploreSteps(expectimax, 2)
+		if err := expectimax.AdvanceToState([]interface{}{1}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := expectimax.settings.transpositions.lookup(3, nil); !ok || expectimax.settings.transpositions.len() != 1 {
+			t.Errorf("Table held %d positions after moving, expected only the new root's.", expectimax.settings.transpositions.len())
+		}
+	})
+
+	t.Run("test a position with a colliding hash isn't taken from the table", func(t *testing.T) {
+		initNodeMemoryPool()
+		// Move 1's position 10 is a different position with the same hash
+		collided := leaf(100.0)
+		collided.hash = 10
+		tree := hashedTree()
+		tree.children[1].children[0] = collided
+
+		expectimax := NewExpectimax(&equalTestGame{&hashedTestGame{newTestGame(tree)}}, testHeuristic, maxChildLikelihood, 1000, WithTranspositionTable(100))
+		exploreSteps(expectimax, 3)
+
+		if value := expectimax.rootNode.children[1].children[0].heuristic; value != 100.0 {
+			t.Errorf("Position colliding with another's hash was valued %g, expected its own heuristic 100.", value)
+		}
+	})
+}
+
+func TestDistinctStateCount(t *testing.T) {
+	t.Run("test a repeated position is counted once", func(t *testing.T) {
+		expectimax := NewExpectimax(&hashedTestGame{newTestGame(hashedTree())}, testHeuristic, maxChildLikelihood, 1000, WithTranspositionTable(100))
+		exploreAll(expectimax)
+
+		if nodes, states := expectimax.GetNodeCount(), expectimax.DistinctStateCount(); nodes != 6 || states != 5 {
+			t.Errorf("Found %d nodes and %d distinct states, expected 6 nodes and 5 states.", nodes, states)
+		}
+	})
+
+	t.Run("test the count is -1 without a table", func(t *testing.T) {
+		expectimax := NewExpectimax(&hashedTestGame{newTestGame(hashedTree())}, testHeuristic, maxChildLikelihood, 1000)
+		exploreAll(expectimax)
+
+		if states := expectimax.DistinctStateCount(); states != -1 {
+			t.Errorf("DistinctStateCount returned %d without a table, expected -1.", states)
+		}
+	})
+}
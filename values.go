@@ -0,0 +1,57 @@
+package expectimax
+
+import "math"
+
+// ValueScale says how heuristic and node values are to be interpreted.
+type ValueScale int
+
+const (
+	// ScoreValues are unbounded scores, averaged as they are.
+	ScoreValues ValueScale = iota
+	// ProbabilityValues are win probabilities. Heuristic values and backups are
+	// clamped to [0, 1] so rounding can't carry them outside it.
+	ProbabilityValues
+	// LogOddsValues are win probabilities like ProbabilityValues, but chance
+	// nodes average their outcomes as log-odds, which stays accurate for
+	// probabilities close to 0 or 1.
+	LogOddsValues
+)
+
+// logOddsLimit keeps log-odds finite for probabilities of exactly 0 or 1.
+const logOddsLimit float64 = 1e-12
+
+func (scale ValueScale) clamp(value float64) float64 {
+	if scale == ScoreValues {
+		return value
+	}
+
+	return math.Max(0.0, math.Min(1.0, value))
+}
+
+func logit(probability float64) float64 {
+	probability = math.Max(logOddsLimit, math.Min(1.0-logOddsLimit, probability))
+	return math.Log(probability / (1.0 - probability))
+}
+
+func logistic(logOdds float64) float64 {
+	return 1.0 / (1.0 + math.Exp(-logOdds))
+}
+
+// valueSum adds up the terms of a backup, with Kahan compensation when asked.
+type valueSum struct {
+	compensated  bool
+	total        float64
+	compensation float64 // Low-order bits lost from total so far, negated
+}
+
+func (sum *valueSum) add(term float64) {
+	if !sum.compensated {
+		sum.total += term
+		return
+	}
+
+	corrected := term - sum.compensation
+	total := sum.total + corrected
+	sum.compensation = (total - sum.total) - corrected
+	sum.total = total
+}
@@ -0,0 +1,79 @@
+package expectimax
+
+import (
+	"fmt"
+	"time"
+)
+
+// runWatchdog reports the search stalling: no nodes explored for the watchdog
+// timeout while explorations are in flight, or the main loop not answering at
+// all. Each stall is reported once, and the search is stopped too if asked for.
+// It runs until the search stops.
+func (this *Expectimax) runWatchdog() {
+	ticker := time.NewTicker(this.watchdogTimeout / 4)
+	defer ticker.Stop()
+
+	lastExploredNodes := this.stats.getExploredNodes()
+	lastProgress := time.Now()
+	reported := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-this.quit:
+			return
+		}
+
+		if exploredNodes := this.stats.getExploredNodes(); exploredNodes != lastExploredNodes {
+			lastExploredNodes = exploredNodes
+			lastProgress = time.Now()
+			reported = false
+			continue
+		}
+		if reported || time.Since(lastProgress) < this.watchdogTimeout {
+			continue
+		}
+
+		var err error
+		if inFlight, responded := this.probeMainLoop(this.watchdogTimeout); !responded {
+			err = fmt.Errorf("expectimax: the main loop hasn't responded in %v, the search may be deadlocked", this.watchdogTimeout)
+		} else if inFlight > 0 {
+			err = fmt.Errorf("expectimax: no nodes explored in %v with %d explorations in flight, the search may be deadlocked", this.watchdogTimeout, inFlight)
+		} else {
+			// Nothing is being explored, so the search is just idle
+			lastProgress = time.Now()
+			continue
+		}
+
+		reported = true
+		this.reportError(err)
+		if this.watchdogStop {
+			this.Stop()
+			return
+		}
+	}
+}
+
+// probeMainLoop asks the main loop how many explorations are in flight, giving up
+// if it doesn't answer within timeout.
+func (this *Expectimax) probeMainLoop(timeout time.Duration) (int, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	answer := make(chan int, 1)
+	select {
+	case this.requestChannel <- func() { answer <- this.inFlight }:
+	case <-timer.C:
+		return 0, false
+	case <-this.quit:
+		return 0, true
+	}
+
+	select {
+	case inFlight := <-answer:
+		return inFlight, true
+	case <-timer.C:
+		return 0, false
+	case <-this.quit:
+		return 0, true
+	}
+}
@@ -0,0 +1,60 @@
+package expectimax
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	t.Run("test a permanently blocked heuristic is reported and stops the search", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		blockingHeuristic := func(game Game) float64 {
+			<-release
+			return 0.0
+		}
+
+		errors := make(chan error, 10)
+		expectimax := NewExpectimax(newTestGame(uniformTree(3, 3)), blockingHeuristic, uniformChildLikelihood, 1000,
+			WithWatchdog(50*time.Millisecond, true), WithErrorCallback(func(err error) { errors <- err }))
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		select {
+		case err := <-errors:
+			if !strings.Contains(err.Error(), "deadlocked") {
+				t.Errorf("Watchdog reported %q, expected a possible deadlock.", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The watchdog didn't report the blocked search.")
+		}
+
+		if !waitFor(time.Second, expectimax.isStopped) {
+			t.Errorf("The search wasn't stopped after the stall was reported.")
+		}
+		if len(errors) != 0 {
+			t.Errorf("Got %d more errors, expected the stall to be reported once.", len(errors))
+		}
+	})
+
+	t.Run("test an idle search isn't reported", func(t *testing.T) {
+		errors := make(chan error, 10)
+		expectimax := NewExpectimax(newTestGame(uniformTree(2, 2)), testHeuristic, uniformChildLikelihood, 1000,
+			WithWatchdog(20*time.Millisecond, true), WithErrorCallback(func(err error) { errors <- err }))
+		defer expectimax.Stop()
+		go expectimax.RunExpectimax()
+
+		if !waitFor(5*time.Second, expectimax.IsFullyExplored) {
+			t.Fatalf("The search didn't finish exploring the tree.")
+		}
+		time.Sleep(200 * time.Millisecond)
+
+		if len(errors) != 0 {
+			t.Errorf("Watchdog reported %v for an idle search.", <-errors)
+		}
+		if expectimax.isStopped() {
+			t.Errorf("Watchdog stopped an idle search.")
+		}
+	})
+}